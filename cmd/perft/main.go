@@ -0,0 +1,124 @@
+package main
+
+import (
+	"chess-frontend/comm/chess"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	fen := flag.String("fen", chess.StartFEN, "起始局面的FEN")
+	depth := flag.Int("depth", 4, "搜索深度")
+	divide := flag.Bool("divide", false, "按第一步拆分输出节点数")
+	suite := flag.Bool("suite", false, "跑一遍标准perft局面, 忽略fen和divide")
+	bitboard := flag.Bool("bitboard", false, "使用位棋盘生成走法, divide模式下不生效")
+	epdPath := flag.String("epd", "", "用EPD文件中的D1到D6操作验证perft, 忽略fen和divide")
+	flag.Parse()
+
+	if *epdPath != "" {
+		if !runEPD(*epdPath, *depth, *bitboard) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *suite {
+		if !runSuite(*depth, *bitboard) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	pos, err := chess.ParsePositionFEN(*fen)
+	if err != nil {
+		fmt.Printf("failed to parse fen: %v\n", err)
+		os.Exit(1)
+	}
+
+	start := time.Now()
+	var total uint64
+	if *divide {
+		for _, e := range pos.PerftDivide(*depth) {
+			fmt.Printf("%s: %d\n", e.Move, e.Nodes)
+			total += e.Nodes
+		}
+		fmt.Println()
+	} else {
+		total = perft(pos, *depth, *bitboard)
+	}
+
+	fmt.Printf("nodes: %d\n", total)
+	fmt.Printf("time: %v\n", time.Since(start))
+}
+
+func perft(pos *chess.Position, depth int, bitboard bool) uint64 {
+	if bitboard {
+		return chess.NewBitboardPosition(pos.Table).Perft(pos.SideToMove, depth)
+	}
+	return pos.Perft(depth)
+}
+
+// 每个局面最多跑到maxDepth层, 全部一致时返回true
+func runSuite(maxDepth int, bitboard bool) bool {
+	ok := true
+	for _, c := range chess.PerftSuite {
+		pos, err := chess.ParsePositionFEN(c.FEN)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", c.Name, err)
+			ok = false
+			continue
+		}
+
+		nodes := make(map[int]uint64)
+		for i, n := range c.Nodes {
+			nodes[i+1] = n
+		}
+		ok = checkPerft(c.Name, pos, nodes, maxDepth, bitboard) && ok
+	}
+	return ok
+}
+
+// EPD文件中的每一行都按照D1到D6操作验证
+func runEPD(path string, maxDepth int, bitboard bool) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("failed to open epd: %v\n", err)
+		return false
+	}
+	defer f.Close()
+
+	epds, err := chess.ReadEPD(f)
+	if err != nil {
+		fmt.Printf("failed to parse epd: %v\n", err)
+		return false
+	}
+
+	ok := true
+	for i, e := range epds {
+		name := e.ID()
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		ok = checkPerft(name, e.Position, e.PerftNodes(), maxDepth, bitboard) && ok
+	}
+	return ok
+}
+
+// 按深度从小到大检查, 遇到第一个不一致的深度就停止
+func checkPerft(name string, pos *chess.Position, nodes map[int]uint64, maxDepth int, bitboard bool) bool {
+	for d := 1; d <= maxDepth; d++ {
+		want, exists := nodes[d]
+		if !exists {
+			continue
+		}
+		got := perft(pos, d, bitboard)
+		if got != want {
+			fmt.Printf("FAIL %s depth %d: got %d, want %d\n", name, d, got, want)
+			return false
+		}
+		fmt.Printf("ok   %s depth %d: %d\n", name, d, got)
+	}
+	return true
+}
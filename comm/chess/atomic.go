@@ -0,0 +1,80 @@
+package chess
+
+// Atomic中吃子时以终点为中心爆炸, 吃子的棋子和周围八个格子上除了兵以外的棋子都被移除
+// 被移除的棋子记录在u中, UnmakeMove时放回去
+func (ct *ChessTable) explode(u *Undo) {
+	tx, ty := MustPositionToIndex(u.Move.ToX, u.Move.ToY)
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			x, y := tx+dx, ty+dy
+			if !onBoard(x, y) {
+				continue
+			}
+			p := ct.GetIndex(x, y)
+			if p == nil || (p.PieceType == ChessPieceTypePawn && (dx != 0 || dy != 0)) {
+				continue
+			}
+			u.exploded = append(u.exploded, ct.ClearIndex(x, y))
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Atomic中side方的王是否正在被将军
+// 双方的王相邻时吃掉对方的王会把自己的王也炸掉, 所以这时不算将军
+func (ct *ChessTable) atomicInCheck(side Side) bool {
+	kx, ky, ok := ct.findKing(side)
+	if !ok {
+		return false
+	}
+	if ox, oy, ok := ct.findKing(side.Opponent()); ok && abs(kx-ox) <= 1 && abs(ky-oy) <= 1 {
+		return false
+	}
+	return ct.isSquareAttacked(kx, ky, side.Opponent())
+}
+
+// Atomic中的一步是否合法: 王不能吃子, 不能炸掉自己的王,
+// 炸掉了对方的王时即使自己被将军也合法, 否则走完之后自己不能被将军
+func (ct *ChessTable) atomicLegal(m Move, side Side) bool {
+	piece := ct.GetPosition(m.FromX, m.FromY)
+	if piece.PieceType == ChessPieceTypeKing && m.Type != MoveTypeCastling && ct.GetPosition(m.ToX, m.ToY) != nil {
+		return false
+	}
+
+	u := ct.MakeMove(m)
+	if u.Captured != nil {
+		ct.explode(&u)
+	}
+	_, _, own := ct.findKing(side)
+	_, _, enemy := ct.findKing(side.Opponent())
+	legal := own && (!enemy || !ct.atomicInCheck(side))
+	ct.UnmakeMove(u)
+	return legal
+}
+
+func (ct *ChessTable) atomicLegalMoves(side Side) []Move {
+	pseudo := ct.pseudoLegalMoves(side)
+	moves := pseudo[:0]
+	for _, m := range pseudo {
+		if ct.atomicLegal(m, side) {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+type atomicRules struct{}
+
+// 刚走完的一方炸掉了对方的王就获胜
+func (atomicRules) Outcome(g *Game) (GameResult, GameResultReason) {
+	if _, _, ok := g.Position.Table.findKing(g.SideToMove()); !ok {
+		return GameResultFromWinner(g.SideToMove().Opponent()), GameResultReasonExplosion
+	}
+	return GameResultOngoing, GameResultReasonNone
+}
@@ -0,0 +1,126 @@
+package chess
+
+// 依次访问by方所有攻击格子(x, y)的棋子, visit返回true时停止访问并返回true
+// 判断格子是否被攻击, 找出所有攻击者和计算攻击图都基于这个函数
+func (ct *ChessTable) visitAttackers(x int, y int, by Side, visit func(p *ChessPiece) bool) bool {
+	// 兵, 从被攻击格子往回看
+	py := y - pawnDirection(by)
+	for _, dx := range [2]int{-1, 1} {
+		if onBoard(x+dx, py) {
+			p := ct.GetIndex(x+dx, py)
+			if p != nil && p.GameSide == by && p.PieceType == ChessPieceTypePawn && visit(p) {
+				return true
+			}
+		}
+	}
+
+	for _, o := range knightOffsets {
+		if onBoard(x+o[0], y+o[1]) {
+			p := ct.GetIndex(x+o[0], y+o[1])
+			if p != nil && p.GameSide == by && p.PieceType == ChessPieceTypeKnight && visit(p) {
+				return true
+			}
+		}
+	}
+
+	for _, o := range kingOffsets {
+		if onBoard(x+o[0], y+o[1]) {
+			p := ct.GetIndex(x+o[0], y+o[1])
+			if p != nil && p.GameSide == by && p.PieceType == ChessPieceTypeKing && visit(p) {
+				return true
+			}
+		}
+	}
+
+	for _, d := range rookDirections {
+		if p := ct.firstPieceInDirection(x, y, d); p != nil && p.GameSide == by &&
+			(p.PieceType == ChessPieceTypeRook || p.PieceType == ChessPieceTypeQueen) && visit(p) {
+			return true
+		}
+	}
+
+	for _, d := range bishopDirections {
+		if p := ct.firstPieceInDirection(x, y, d); p != nil && p.GameSide == by &&
+			(p.PieceType == ChessPieceTypeBishop || p.PieceType == ChessPieceTypeQueen) && visit(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stopAtFirst(*ChessPiece) bool {
+	return true
+}
+
+// 判断格子(x, y)是否被by方攻击
+func (ct *ChessTable) isSquareAttacked(x int, y int, by Side) bool {
+	return ct.visitAttackers(x, y, by, stopAtFirst)
+}
+
+// 格子是否被by方攻击, 格子上有没有棋子都可以
+func (ct *ChessTable) IsSquareAttacked(X rune, Y int, by Side) bool {
+	x, y := MustPositionToIndex(X, Y)
+	return ct.isSquareAttacked(x, y, by)
+}
+
+// by方所有攻击这个格子的棋子, 不考虑牵制
+func (ct *ChessTable) Attackers(X rune, Y int, by Side) []*ChessPiece {
+	x, y := MustPositionToIndex(X, Y)
+	var attackers []*ChessPiece
+	ct.visitAttackers(x, y, by, func(p *ChessPiece) bool {
+		attackers = append(attackers, p)
+		return false
+	})
+	return attackers
+}
+
+// side方每个格子被多少个棋子攻击, 下标和ChessTable一致
+func (ct *ChessTable) AttackMap(side Side) [64]int {
+	var m [64]int
+	for i := 0; i < 64; i++ {
+		ct.visitAttackers(i%8, i/8, side, func(*ChessPiece) bool {
+			m[i]++
+			return false
+		})
+	}
+	return m
+}
+
+// 正在将军side方的棋子, 没有被将军时返回nil
+func (ct *ChessTable) CheckingPieces(side Side) []*ChessPiece {
+	kx, ky, ok := ct.findKing(side)
+	if !ok {
+		return nil
+	}
+	X, Y := indexToPosition(kx, ky)
+	return ct.Attackers(X, Y, side.Opponent())
+}
+
+// side方被对方攻击并且没有己方棋子保护的棋子, 不包括王
+func (ct *ChessTable) HangingPieces(side Side) []*ChessPiece {
+	attacked := ct.AttackMap(side.Opponent())
+	defended := ct.AttackMap(side)
+
+	var hanging []*ChessPiece
+	for i := 0; i < 64; i++ {
+		p := ct[i]
+		if p == nil || p.GameSide != side || p.PieceType == ChessPieceTypeKing {
+			continue
+		}
+		if attacked[i] > 0 && defended[i] == 0 {
+			hanging = append(hanging, p)
+		}
+	}
+	return hanging
+}
+
+// 沿某个方向遇到的第一个棋子, 没有返回nil
+func (ct *ChessTable) firstPieceInDirection(x int, y int, d [2]int) *ChessPiece {
+	for nx, ny := x+d[0], y+d[1]; onBoard(nx, ny); nx, ny = nx+d[0], ny+d[1] {
+		if p := ct.GetIndex(nx, ny); p != nil {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,605 @@
+package chess
+
+import "math/bits"
+
+// 每一位表示一个格子, 下标和ChessTable一致, 也就是y*8+x
+type Bitboard uint64
+
+func squareBB(index int) Bitboard {
+	return 1 << index
+}
+
+// 最低位的1所在的下标, b不能为0
+func (b Bitboard) lowest() int {
+	return bits.TrailingZeros64(uint64(b))
+}
+
+// 最高位的1所在的下标, b不能为0
+func (b Bitboard) highest() int {
+	return 63 - bits.LeadingZeros64(uint64(b))
+}
+
+func (b Bitboard) Count() int {
+	return bits.OnesCount64(uint64(b))
+}
+
+// 用位棋盘表示的局面, 用于需要大量走法生成的场景, 比如搜索
+// 除了棋子的位置, 还保存了Moved, PawnMovedTwoLastTime和Promoted, 所以可以和ChessTable无损地互相转换
+type BitboardPosition struct {
+	// 每一方每种棋子的位置, 下标分别是Side和ChessPieceType
+	Pieces [2][6]Bitboard
+	// 每一方所有棋子的位置
+	Occupied [2]Bitboard
+	// 移动过的棋子所在的格子
+	Moved Bitboard
+	// 上一步走了两格的兵所在的格子
+	PawnMovedTwo Bitboard
+	// 由兵升变而来的棋子所在的格子
+	Promoted Bitboard
+}
+
+// 射线的八个方向, 前四个方向下标递增, 后四个方向下标递减
+const (
+	directionNorth = iota
+	directionNorthEast
+	directionEast
+	directionNorthWest
+	directionSouth
+	directionSouthWest
+	directionWest
+	directionSouthEast
+)
+
+var directionOffsets = [8][2]int{{0, 1}, {1, 1}, {1, 0}, {-1, 1}, {0, -1}, {-1, -1}, {-1, 0}, {1, -1}}
+
+var (
+	knightAttacksBB [64]Bitboard
+	kingAttacksBB   [64]Bitboard
+	// 下标是兵所属的一方
+	pawnAttacksBB [2][64]Bitboard
+	// 从某个格子出发沿某个方向的射线, 不包括起点
+	raysBB [8][64]Bitboard
+	// 两个格子在同一条直线或斜线上时, 它们之间的格子, 不包括两端
+	betweenBB [64][64]Bitboard
+	// 两个格子在同一条直线或斜线上时, 穿过它们的整条线, 包括两端
+	lineBB [64][64]Bitboard
+)
+
+func init() {
+	for i := 0; i < 64; i++ {
+		x, y := i%8, i/8
+		for _, o := range knightOffsets {
+			if onBoard(x+o[0], y+o[1]) {
+				knightAttacksBB[i] |= squareBB((y+o[1])*8 + x + o[0])
+			}
+		}
+		for _, o := range kingOffsets {
+			if onBoard(x+o[0], y+o[1]) {
+				kingAttacksBB[i] |= squareBB((y+o[1])*8 + x + o[0])
+			}
+		}
+		for _, side := range [2]Side{SideWhite, SideBlack} {
+			ny := y + pawnDirection(side)
+			for _, dx := range [2]int{-1, 1} {
+				if onBoard(x+dx, ny) {
+					pawnAttacksBB[side][i] |= squareBB(ny*8 + x + dx)
+				}
+			}
+		}
+		for d, o := range directionOffsets {
+			for nx, ny := x+o[0], y+o[1]; onBoard(nx, ny); nx, ny = nx+o[0], ny+o[1] {
+				raysBB[d][i] |= squareBB(ny*8 + nx)
+			}
+		}
+	}
+
+	// 相反的方向下标相差4
+	for i := 0; i < 64; i++ {
+		for d := 0; d < 8; d++ {
+			line := raysBB[d][i] | raysBB[(d+4)%8][i] | squareBB(i)
+			for bb := raysBB[d][i]; bb != 0; bb &= bb - 1 {
+				j := bb.lowest()
+				betweenBB[i][j] = raysBB[d][i] &^ raysBB[d][j] &^ squareBB(j)
+				lineBB[i][j] = line
+			}
+		}
+	}
+}
+
+// 射线上离起点最近的棋子, blockers不能为0
+func nearestOnRay(blockers Bitboard, direction int) int {
+	if direction < directionSouth {
+		return blockers.lowest()
+	}
+	return blockers.highest()
+}
+
+// 经典的射线算法, 找到射线上第一个阻挡的棋子, 把它之后的部分去掉
+func rayAttacks(index int, occupied Bitboard, direction int) Bitboard {
+	ray := raysBB[direction][index]
+	blockers := ray & occupied
+	if blockers == 0 {
+		return ray
+	}
+	if direction < directionSouth {
+		return ray ^ raysBB[direction][blockers.lowest()]
+	}
+	return ray ^ raysBB[direction][blockers.highest()]
+}
+
+func rookAttacks(index int, occupied Bitboard) Bitboard {
+	return rayAttacks(index, occupied, directionNorth) | rayAttacks(index, occupied, directionEast) |
+		rayAttacks(index, occupied, directionSouth) | rayAttacks(index, occupied, directionWest)
+}
+
+func bishopAttacks(index int, occupied Bitboard) Bitboard {
+	return rayAttacks(index, occupied, directionNorthEast) | rayAttacks(index, occupied, directionNorthWest) |
+		rayAttacks(index, occupied, directionSouthEast) | rayAttacks(index, occupied, directionSouthWest)
+}
+
+// 从ChessTable转换
+func NewBitboardPosition(ct *ChessTable) *BitboardPosition {
+	var b BitboardPosition
+	for i := 0; i < 64; i++ {
+		p := ct[i]
+		if p == nil {
+			continue
+		}
+		b.Pieces[p.GameSide][p.PieceType] |= squareBB(i)
+		b.Occupied[p.GameSide] |= squareBB(i)
+		if p.Moved {
+			b.Moved |= squareBB(i)
+		}
+		if p.PawnMovedTwoLastTime {
+			b.PawnMovedTwo |= squareBB(i)
+		}
+		if p.Promoted {
+			b.Promoted |= squareBB(i)
+		}
+	}
+	return &b
+}
+
+// 转换回ChessTable
+func (b *BitboardPosition) ToTable() *ChessTable {
+	var table ChessTable
+	for side := 0; side < 2; side++ {
+		for t := 0; t < 6; t++ {
+			for bb := b.Pieces[side][t]; bb != 0; bb &= bb - 1 {
+				i := bb.lowest()
+				p := &ChessPiece{
+					PieceType:            ChessPieceType(t),
+					GameSide:             Side(side),
+					Moved:                b.Moved&squareBB(i) != 0,
+					PawnMovedTwoLastTime: b.PawnMovedTwo&squareBB(i) != 0,
+					Promoted:             b.Promoted&squareBB(i) != 0,
+				}
+				p.X, p.Y = indexToPosition(i%8, i/8)
+				table[i] = p
+			}
+		}
+	}
+	return &table
+}
+
+func (b *BitboardPosition) occupiedAll() Bitboard {
+	return b.Occupied[SideWhite] | b.Occupied[SideBlack]
+}
+
+// 某个格子上的棋子, 没有时ok为false
+func (b *BitboardPosition) pieceAt(index int) (side Side, t ChessPieceType, ok bool) {
+	sq := squareBB(index)
+	for s := 0; s < 2; s++ {
+		if b.Occupied[s]&sq == 0 {
+			continue
+		}
+		for pt := 0; pt < 6; pt++ {
+			if b.Pieces[s][pt]&sq != 0 {
+				return Side(s), ChessPieceType(pt), true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// 格子是否被by方攻击
+func (b *BitboardPosition) isSquareAttacked(index int, by Side) bool {
+	own := &b.Pieces[by]
+	occupied := b.occupiedAll()
+	if pawnAttacksBB[by.Opponent()][index]&own[ChessPieceTypePawn] != 0 {
+		return true
+	}
+	if knightAttacksBB[index]&own[ChessPieceTypeKnight] != 0 {
+		return true
+	}
+	if kingAttacksBB[index]&own[ChessPieceTypeKing] != 0 {
+		return true
+	}
+	if bishopAttacks(index, occupied)&(own[ChessPieceTypeBishop]|own[ChessPieceTypeQueen]) != 0 {
+		return true
+	}
+	return rookAttacks(index, occupied)&(own[ChessPieceTypeRook]|own[ChessPieceTypeQueen]) != 0
+}
+
+// 在占用情况为occupied时, by方所有攻击这个格子的棋子
+func (b *BitboardPosition) attackersTo(index int, by Side, occupied Bitboard) Bitboard {
+	own := &b.Pieces[by]
+	return pawnAttacksBB[by.Opponent()][index]&own[ChessPieceTypePawn] |
+		knightAttacksBB[index]&own[ChessPieceTypeKnight] |
+		kingAttacksBB[index]&own[ChessPieceTypeKing] |
+		bishopAttacks(index, occupied)&(own[ChessPieceTypeBishop]|own[ChessPieceTypeQueen]) |
+		rookAttacks(index, occupied)&(own[ChessPieceTypeRook]|own[ChessPieceTypeQueen])
+}
+
+// side方被牵制的棋子, 也就是王和对方的远程棋子之间唯一的己方棋子
+func (b *BitboardPosition) pinned(side Side, king int) Bitboard {
+	enemy := &b.Pieces[side.Opponent()]
+	occupied := b.occupiedAll()
+	var pinned Bitboard
+	for d := 0; d < 8; d++ {
+		// 偶数下标是直线方向, 奇数下标是斜线方向
+		sliders := enemy[ChessPieceTypeBishop] | enemy[ChessPieceTypeQueen]
+		if d%2 == 0 {
+			sliders = enemy[ChessPieceTypeRook] | enemy[ChessPieceTypeQueen]
+		}
+		blockers := raysBB[d][king] & occupied
+		if blockers == 0 {
+			continue
+		}
+		first := nearestOnRay(blockers, d)
+		rest := blockers &^ squareBB(first)
+		if b.Occupied[side]&squareBB(first) == 0 || rest == 0 {
+			continue
+		}
+		if sliders&squareBB(nearestOnRay(rest, d)) != 0 {
+			pinned |= squareBB(first)
+		}
+	}
+	return pinned
+}
+
+func (b *BitboardPosition) InCheck(side Side) bool {
+	king := b.Pieces[side][ChessPieceTypeKing]
+	if king == 0 {
+		return false
+	}
+	return b.isSquareAttacked(king.lowest(), side.Opponent())
+}
+
+func appendBitboardMoves(moves []Move, t MoveType, from int, targets Bitboard) []Move {
+	for ; targets != 0; targets &= targets - 1 {
+		to := targets.lowest()
+		moves = append(moves, newMove(t, from%8, from/8, to%8, to/8))
+	}
+	return moves
+}
+
+// 伪合法的走法, 和ChessTable生成的走法一致
+func (b *BitboardPosition) pseudoLegalMoves(side Side) []Move {
+	moves := make([]Move, 0, 48)
+	own := &b.Pieces[side]
+	occupied := b.occupiedAll()
+	notOwn := ^b.Occupied[side]
+
+	for bb := own[ChessPieceTypeKnight]; bb != 0; bb &= bb - 1 {
+		from := bb.lowest()
+		moves = appendBitboardMoves(moves, MoveTypeNormal, from, knightAttacksBB[from]&notOwn)
+	}
+	for bb := own[ChessPieceTypeBishop] | own[ChessPieceTypeQueen]; bb != 0; bb &= bb - 1 {
+		from := bb.lowest()
+		moves = appendBitboardMoves(moves, MoveTypeNormal, from, bishopAttacks(from, occupied)&notOwn)
+	}
+	for bb := own[ChessPieceTypeRook] | own[ChessPieceTypeQueen]; bb != 0; bb &= bb - 1 {
+		from := bb.lowest()
+		moves = appendBitboardMoves(moves, MoveTypeNormal, from, rookAttacks(from, occupied)&notOwn)
+	}
+	for bb := own[ChessPieceTypeKing]; bb != 0; bb &= bb - 1 {
+		from := bb.lowest()
+		moves = appendBitboardMoves(moves, MoveTypeNormal, from, kingAttacksBB[from]&notOwn)
+		moves = b.appendCastlingMoves(moves, side, from)
+	}
+
+	moves = b.appendPawnMoves(moves, side)
+	return moves
+}
+
+func (b *BitboardPosition) appendPawnMoves(moves []Move, side Side) []Move {
+	occupied := b.occupiedAll()
+	enemy := b.Occupied[side.Opponent()]
+	dir := pawnDirection(side)
+	startRank := backRank(side) + dir
+	epRank := backRank(side.Opponent()) + 3*pawnDirection(side.Opponent())
+
+	for bb := b.Pieces[side][ChessPieceTypePawn]; bb != 0; bb &= bb - 1 {
+		from := bb.lowest()
+		x, y := from%8, from/8
+
+		one := from + 8*dir
+		if onBoard(x, y+dir) && occupied&squareBB(one) == 0 {
+			moves = appendPawnMove(moves, x, y, x, y+dir)
+			two := one + 8*dir
+			if y == startRank && occupied&squareBB(two) == 0 {
+				moves = append(moves, newMove(MoveTypeNormal, x, y, x, y+2*dir))
+			}
+		}
+
+		for targets := pawnAttacksBB[side][from] & enemy; targets != 0; targets &= targets - 1 {
+			to := targets.lowest()
+			moves = appendPawnMove(moves, x, y, to%8, to/8)
+		}
+
+		if y == epRank {
+			for _, dx := range [2]int{-1, 1} {
+				if !onBoard(x+dx, y) {
+					continue
+				}
+				victim := squareBB(from + dx)
+				if b.Pieces[side.Opponent()][ChessPieceTypePawn]&b.PawnMovedTwo&victim != 0 &&
+					occupied&squareBB(from+dx+8*dir) == 0 {
+					moves = append(moves, newMove(MoveTypeEnPassant, x, y, x+dx, y+dir))
+				}
+			}
+		}
+	}
+	return moves
+}
+
+// 规则和ChessTable.appendCastlingMoves相同, 同样支持Chess960
+func (b *BitboardPosition) appendCastlingMoves(moves []Move, side Side, from int) []Move {
+	y := backRank(side)
+	x := from % 8
+	if from/8 != y || b.Moved&squareBB(from) != 0 {
+		return moves
+	}
+	opponent := side.Opponent()
+	occupied := b.occupiedAll()
+
+	for _, kingside := range [2]bool{true, false} {
+		rookX, ok := b.castlingRookX(side, x, kingside)
+		if !ok {
+			continue
+		}
+		kingToX, rookToX := castlingTargetFiles(kingside)
+		if occupied&^squareBB(from)&^squareBB(y*8+rookX)&castlingSpanBB(y, x, kingToX, rookX, rookToX) != 0 {
+			continue
+		}
+
+		safe := true
+		for kx := x; ; kx += sign(kingToX - x) {
+			if b.isSquareAttacked(y*8+kx, opponent) {
+				safe = false
+				break
+			}
+			if kx == kingToX {
+				break
+			}
+		}
+		if safe {
+			moves = append(moves, newMove(MoveTypeCastling, x, y, kingToX, y))
+		}
+	}
+	return moves
+}
+
+// 王某一侧用来易位的车所在的列, 和ChessTable.castlingRookX相同
+func (b *BitboardPosition) castlingRookX(side Side, kingX int, kingside bool) (int, bool) {
+	y := backRank(side)
+	unmovedRooks := b.Pieces[side][ChessPieceTypeRook] &^ b.Moved
+	x, step := 0, 1
+	if kingside {
+		x, step = 7, -1
+	}
+	for ; x != kingX; x += step {
+		if unmovedRooks&squareBB(y*8+x) != 0 {
+			return x, true
+		}
+	}
+	return 0, false
+}
+
+// 第y行上覆盖这几列的区间
+func castlingSpanBB(y int, files ...int) Bitboard {
+	lo, hi := files[0], files[0]
+	for _, x := range files {
+		if x < lo {
+			lo = x
+		}
+		if x > hi {
+			hi = x
+		}
+	}
+	var span Bitboard
+	for x := lo; x <= hi; x++ {
+		span |= squareBB(y*8 + x)
+	}
+	return span
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func (b *BitboardPosition) removePiece(side Side, t ChessPieceType, index int) {
+	b.Pieces[side][t] &^= squareBB(index)
+	b.Occupied[side] &^= squareBB(index)
+}
+
+func (b *BitboardPosition) putPiece(side Side, t ChessPieceType, index int) {
+	b.Pieces[side][t] |= squareBB(index)
+	b.Occupied[side] |= squareBB(index)
+}
+
+// 执行一步棋, 不做合法性检查, 位棋盘很小, 需要撤销时直接保存一份副本即可
+func (b *BitboardPosition) MakeMove(m Move) {
+	fx, fy := MustPositionToIndex(m.FromX, m.FromY)
+	tx, ty := MustPositionToIndex(m.ToX, m.ToY)
+	from, to := fy*8+fx, ty*8+tx
+
+	side, t, ok := b.pieceAt(from)
+	if !ok {
+		return
+	}
+
+	if m.Type == MoveTypeCastling {
+		rookFromX, _ := b.castlingRookX(side, fx, isKingsideCastling(m))
+		_, rookToX := castlingTargetFiles(isKingsideCastling(m))
+		rookFrom, rookTo := fy*8+rookFromX, fy*8+rookToX
+		b.removePiece(side, ChessPieceTypeRook, rookFrom)
+		b.removePiece(side, t, from)
+		b.putPiece(side, ChessPieceTypeRook, rookTo)
+		b.putPiece(side, t, to)
+		// 和普通的走法一样, 先清掉离开的格子再标记到达的格子, Chess960中它们可能重叠
+		b.Moved &^= squareBB(from) | squareBB(rookFrom)
+		b.Promoted &^= squareBB(from) | squareBB(rookFrom)
+		b.Moved |= squareBB(rookTo) | squareBB(to)
+		b.PawnMovedTwo = 0
+		return
+	}
+
+	captured := to
+	if m.Type == MoveTypeEnPassant {
+		captured = fy*8 + tx
+	}
+	if cs, ct, ok := b.pieceAt(captured); ok {
+		b.removePiece(cs, ct, captured)
+	}
+
+	b.removePiece(side, t, from)
+	if m.Type == MoveTypePromotion {
+		b.putPiece(side, m.Promotion, to)
+	} else {
+		b.putPiece(side, t, to)
+	}
+
+	b.Moved &^= squareBB(from) | squareBB(captured)
+	b.Moved |= squareBB(to)
+
+	promoted := m.Type == MoveTypePromotion || b.Promoted&squareBB(from) != 0
+	b.Promoted &^= squareBB(from) | squareBB(captured)
+	if promoted {
+		b.Promoted |= squareBB(to)
+	}
+
+	b.PawnMovedTwo = 0
+	if t == ChessPieceTypePawn && (ty-fy == 2 || fy-ty == 2) {
+		b.PawnMovedTwo = squareBB(to)
+	}
+}
+
+func (b *BitboardPosition) LegalMoves(side Side) []Move {
+	return b.appendLegalMoves(make([]Move, 0, 48), side)
+}
+
+// 用将军和牵制的掩码直接生成合法的走法, 不需要逐个执行再检查
+// 吃过路兵和易位比较少见, 也有一些特殊情况, 仍然执行之后再检查
+func (b *BitboardPosition) appendLegalMoves(moves []Move, side Side) []Move {
+	kings := b.Pieces[side][ChessPieceTypeKing]
+	if kings == 0 {
+		// 没有王时不存在将军, 和ChessTable一致
+		return append(moves, b.pseudoLegalMoves(side)...)
+	}
+	king := kings.lowest()
+	opponent := side.Opponent()
+	own := &b.Pieces[side]
+	occupied := b.occupiedAll()
+	notOwn := ^b.Occupied[side]
+
+	// 判断王的目标格子时先把王拿掉, 否则沿着将军的方向后退会被误判为安全
+	withoutKing := occupied &^ squareBB(king)
+	for targets := kingAttacksBB[king] & notOwn; targets != 0; targets &= targets - 1 {
+		to := targets.lowest()
+		if b.attackersTo(to, opponent, withoutKing) == 0 {
+			moves = append(moves, newMove(MoveTypeNormal, king%8, king/8, to%8, to/8))
+		}
+	}
+
+	// 双将时只能走王
+	checkers := b.attackersTo(king, opponent, occupied)
+	if checkers.Count() > 1 {
+		return moves
+	}
+	// 被将军时其他棋子只能吃掉将军的棋子或者挡在中间
+	checkMask := ^Bitboard(0)
+	if checkers != 0 {
+		checkMask = checkers | betweenBB[king][checkers.lowest()]
+	}
+	pinned := b.pinned(side, king)
+
+	// 被牵制的棋子只能沿着和王连成的线移动
+	targetsFrom := func(from int, attacks Bitboard) Bitboard {
+		targets := attacks & notOwn & checkMask
+		if pinned&squareBB(from) != 0 {
+			targets &= lineBB[king][from]
+		}
+		return targets
+	}
+	for bb := own[ChessPieceTypeKnight]; bb != 0; bb &= bb - 1 {
+		from := bb.lowest()
+		moves = appendBitboardMoves(moves, MoveTypeNormal, from, targetsFrom(from, knightAttacksBB[from]))
+	}
+	for bb := own[ChessPieceTypeBishop] | own[ChessPieceTypeQueen]; bb != 0; bb &= bb - 1 {
+		from := bb.lowest()
+		moves = appendBitboardMoves(moves, MoveTypeNormal, from, targetsFrom(from, bishopAttacks(from, occupied)))
+	}
+	for bb := own[ChessPieceTypeRook] | own[ChessPieceTypeQueen]; bb != 0; bb &= bb - 1 {
+		from := bb.lowest()
+		moves = appendBitboardMoves(moves, MoveTypeNormal, from, targetsFrom(from, rookAttacks(from, occupied)))
+	}
+
+	// 兵的走法种类较多, 先按伪合法生成再用同样的掩码过滤
+	start := len(moves)
+	moves = b.appendPawnMoves(moves, side)
+	if checkers == 0 {
+		moves = b.appendCastlingMoves(moves, side, king)
+	}
+	kept := moves[:start]
+	for _, m := range moves[start:] {
+		if m.Type == MoveTypeEnPassant || m.Type == MoveTypeCastling {
+			after := *b
+			after.MakeMove(m)
+			if !after.InCheck(side) {
+				kept = append(kept, m)
+			}
+			continue
+		}
+		from := int(m.FromX-'a') + (m.FromY-1)*8
+		to := int(m.ToX-'a') + (m.ToY-1)*8
+		if targetsFrom(from, squareBB(to))&squareBB(to) != 0 {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// 和Position.Perft相同, 用来验证位棋盘的走法生成并比较速度
+func (b *BitboardPosition) Perft(side Side, depth int) uint64 {
+	if depth <= 0 {
+		return 1
+	}
+	return bitboardPerft(*b, side, depth, make([][]Move, depth))
+}
+
+// 递归时按值传递位棋盘, 这样每一层的副本都留在栈上
+// bufs[i]是深度为depth-i的那一层复用的着法数组, 避免频繁分配内存
+func bitboardPerft(b BitboardPosition, side Side, depth int, bufs [][]Move) uint64 {
+	moves := b.appendLegalMoves(bufs[0][:0], side)
+	bufs[0] = moves
+	if depth == 1 {
+		return uint64(len(moves))
+	}
+
+	var nodes uint64
+	for _, m := range moves {
+		after := b
+		after.MakeMove(m)
+		nodes += bitboardPerft(after, side.Opponent(), depth-1, bufs[1:])
+	}
+	return nodes
+}
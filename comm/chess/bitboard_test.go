@@ -0,0 +1,37 @@
+package chess
+
+import "testing"
+
+// 在棋盘上走一步再转换, 应该和直接在位棋盘上走这一步得到同样的结果
+func compareMakeMove(t *testing.T, ct *ChessTable, side Side, depth int) {
+	t.Helper()
+	for _, m := range ct.LegalMoves(side) {
+		b := NewBitboardPosition(ct)
+		b.MakeMove(m)
+		u := ct.MakeMove(m)
+		if want := NewBitboardPosition(ct); *b != *want {
+			t.Fatalf("%s after %s: bitboard %+v, want %+v", ct.FEN(side.Opponent(), 0, 1), m, *b, *want)
+		}
+		if depth > 1 {
+			compareMakeMove(t, ct, side.Opponent(), depth-1)
+		}
+		ct.UnmakeMove(u)
+	}
+}
+
+func TestBitboardMakeMove(t *testing.T) {
+	fens := []string{
+		StartFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		// Chess960中王和车易位后落在对方原来的格子上
+		"1r4kr/8/8/8/8/8/8/1R4KR w HBhb - 0 1",
+		"6k1/8/8/8/8/8/8/RK6 w A - 0 1",
+	}
+	for _, fen := range fens {
+		p, err := ParsePositionFEN(fen)
+		if err != nil {
+			t.Fatalf("parse fen %q: %v", fen, err)
+		}
+		compareMakeMove(t, p.Table, p.SideToMove, 2)
+	}
+}
@@ -0,0 +1,107 @@
+package chess
+
+// 易位之后王和车所在的列, 标准国际象棋和Chess960相同
+const (
+	kingsideKingToX  = 6
+	kingsideRookToX  = 5
+	queensideKingToX = 2
+	queensideRookToX = 3
+)
+
+// 易位之后王和车所在的列
+func castlingTargetFiles(kingside bool) (int, int) {
+	if kingside {
+		return kingsideKingToX, kingsideRookToX
+	}
+	return queensideKingToX, queensideRookToX
+}
+
+// 王总是走到g或者c, 所以根据终点判断是短易位还是长易位
+// Chess960中王可能本来就在g或者c上, 这时起点和终点相同
+func isKingsideCastling(m Move) bool {
+	return m.ToX == 'a'+kingsideKingToX
+}
+
+// 底线上没有移动过的王所在的列
+func (ct *ChessTable) unmovedKingX(side Side) (int, bool) {
+	y := backRank(side)
+	for x := 0; x < 8; x++ {
+		p := ct.GetIndex(x, y)
+		if p != nil && p.PieceType == ChessPieceTypeKing && p.GameSide == side && !p.Moved {
+			return x, true
+		}
+	}
+	return 0, false
+}
+
+// 王某一侧用来易位的车所在的列, 也就是这一侧底线上没有移动过的车中离角最近的那个
+func (ct *ChessTable) castlingRookX(side Side, kingX int, kingside bool) (int, bool) {
+	y := backRank(side)
+	x, step := 0, 1
+	if kingside {
+		x, step = 7, -1
+	}
+	for ; x != kingX; x += step {
+		p := ct.GetIndex(x, y)
+		if p != nil && p.PieceType == ChessPieceTypeRook && p.GameSide == side && !p.Moved {
+			return x, true
+		}
+	}
+	return 0, false
+}
+
+// 王和车从起点到终点经过的格子上, 除了它们自己以外不能有别的棋子
+// 两段路线总是连在一起的, 所以检查覆盖它们的整个区间即可
+func (ct *ChessTable) castlingPathClear(y int, kingX int, kingToX int, rookX int, rookToX int) bool {
+	lo, hi := kingX, kingX
+	for _, x := range [3]int{kingToX, rookX, rookToX} {
+		if x < lo {
+			lo = x
+		}
+		if x > hi {
+			hi = x
+		}
+	}
+	for x := lo; x <= hi; x++ {
+		if x != kingX && x != rookX && ct.GetIndex(x, y) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// 易位时车的起点和终点所在的列, 必须在执行易位之前调用
+func (ct *ChessTable) castlingRookFiles(m Move) (int, int) {
+	kx, ky := MustPositionToIndex(m.FromX, m.FromY)
+	kingside := isKingsideCastling(m)
+	rookX, _ := ct.castlingRookX(ct.GetIndex(kx, ky).GameSide, kx, kingside)
+	_, rookToX := castlingTargetFiles(kingside)
+	return rookX, rookToX
+}
+
+// 轮到的一方的短易位或者长易位, 不能易位时ok为false
+func (p *Position) CastlingMove(kingside bool) (Move, bool) {
+	for _, m := range p.LegalMoves() {
+		if m.Type == MoveTypeCastling && isKingsideCastling(m) == kingside {
+			return m, true
+		}
+	}
+	return Move{}, false
+}
+
+// 按照起点和终点查找轮到的一方的着法, 升变时返回其中任意一个
+// Chess960中王的普通移动和易位可能有相同的起点和终点, 这时返回普通移动, 易位用CastlingMove查找
+func (p *Position) MoveBySquares(fromX rune, fromY int, toX rune, toY int) (Move, bool) {
+	var found Move
+	ok := false
+	for _, m := range p.LegalMoves() {
+		if m.Type == MoveTypeDrop || m.FromX != fromX || m.FromY != fromY || m.ToX != toX || m.ToY != toY {
+			continue
+		}
+		if m.Type != MoveTypeCastling {
+			return m, true
+		}
+		found, ok = m, true
+	}
+	return found, ok
+}
@@ -0,0 +1,67 @@
+package chess
+
+import "testing"
+
+// Chess960中王的普通移动和易位可能有相同的起点和终点, 坐标记法和查找都要能区分它们
+func TestChess960CastlingSharesSquares(t *testing.T) {
+	cases := []struct {
+		name     string
+		fen      string
+		kingside bool
+		// 王的普通移动, 易位的王走到同一个格子, 没有时为空
+		kingMove string
+		castling string
+	}{
+		{"long castle onto the king's step", "6k1/8/8/8/8/8/8/RK6 w A - 0 1", false, "b1c1", "O-O-O"},
+		{"short castle with the king already on g1", "6k1/8/8/8/8/8/8/6KR w H - 0 1", true, "", "O-O"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			p, err := ParsePositionFEN(c.fen)
+			if err != nil {
+				t.Fatalf("parse fen: %v", err)
+			}
+			castle, ok := p.CastlingMove(c.kingside)
+			if !ok {
+				t.Fatal("castling is not legal")
+			}
+			if castle.String() != c.castling {
+				t.Errorf("castling written as %q, want %q", castle.String(), c.castling)
+			}
+			if !p.Table.IsLegalMove(castle) {
+				t.Error("IsLegalMove rejects the castle")
+			}
+
+			seen := map[string]bool{}
+			for _, m := range p.LegalMoves() {
+				if seen[m.String()] {
+					t.Errorf("two legal moves written as %q", m.String())
+				}
+				seen[m.String()] = true
+			}
+
+			m, ok := p.MoveBySquares(castle.FromX, castle.FromY, castle.ToX, castle.ToY)
+			if c.kingMove == "" {
+				if ok && m.Type != MoveTypeCastling {
+					t.Errorf("squares of the castle found %v", m)
+				}
+				return
+			}
+			if !ok || m.Type != MoveTypeNormal || m.String() != c.kingMove {
+				t.Fatalf("squares of the castle found %v, want the king move %s", m, c.kingMove)
+			}
+			if !p.Table.IsLegalMove(m) {
+				t.Error("IsLegalMove rejects the king move")
+			}
+
+			// 两步走完之后车的位置不同
+			withCastle, withKingMove := p.Copy(), p.Copy()
+			withCastle.MakeMove(castle)
+			withKingMove.MakeMove(m)
+			if withCastle.Table.SamePlacement(withKingMove.Table) {
+				t.Error("castle and king move give the same placement")
+			}
+		})
+	}
+}
@@ -41,6 +41,9 @@ type ChessPiece struct {
 
 	// 给兵留的变量, 这是用来判定是否吃过路兵的
 	PawnMovedTwoLastTime bool
+
+	// 是否由兵升变而来, Crazyhouse中被吃掉后变回兵
+	Promoted bool
 }
 
 // 棋盘类型
@@ -87,6 +90,7 @@ func (ct *ChessTable) Copy() *ChessTable {
 				GameSide:             ct[i].GameSide,
 				Moved:                ct[i].Moved,
 				PawnMovedTwoLastTime: ct[i].PawnMovedTwoLastTime,
+				Promoted:             ct[i].Promoted,
 			}
 
 			table[i] = newPiece
@@ -192,6 +196,76 @@ func NewChessTable() *ChessTable {
 	return &table
 }
 
+// Chess960中和标准开局相同的编号
+const Chess960StandardIndex = 518
+
+// 按照Scharnagl编号生成Chess960的开局, index从0到959, 518是标准开局
+// 黑方的底线和白方对称, 兵的位置和标准开局相同
+func NewChess960Table(index int) *ChessTable {
+	if index < 0 || index >= 960 {
+		panic("chess960 index out of range")
+	}
+
+	var backRankTypes [8]ChessPieceType
+	placed := [8]bool{}
+	place := func(x int, t ChessPieceType) {
+		backRankTypes[x] = t
+		placed[x] = true
+	}
+	// 第n个空格子所在的列
+	nthEmpty := func(n int) int {
+		for x := 0; x < 8; x++ {
+			if !placed[x] {
+				if n == 0 {
+					return x
+				}
+				n--
+			}
+		}
+		panic("unreachable")
+	}
+
+	n := index
+	// 白格象在bdfh, 黑格象在aceg
+	place(n%4*2+1, ChessPieceTypeBishop)
+	n /= 4
+	place(n%4*2, ChessPieceTypeBishop)
+	n /= 4
+	place(nthEmpty(n%6), ChessPieceTypeQueen)
+	n /= 6
+
+	// 剩下五个空格子中两个马的位置, 一共十种
+	knights := [10][2]int{{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4}}[n]
+	second := nthEmpty(knights[1])
+	place(nthEmpty(knights[0]), ChessPieceTypeKnight)
+	place(second, ChessPieceTypeKnight)
+
+	// 剩下的三个格子从左到右是车, 王, 车
+	place(nthEmpty(0), ChessPieceTypeRook)
+	place(nthEmpty(0), ChessPieceTypeKing)
+	place(nthEmpty(0), ChessPieceTypeRook)
+
+	var table ChessTable
+	for x := 0; x < 8; x++ {
+		X := rune('a' + x)
+		table.SetPosition(&ChessPiece{X: X, Y: 1, PieceType: backRankTypes[x], GameSide: SideWhite, Moved: false})
+		table.SetPosition(&ChessPiece{X: X, Y: 2, PieceType: ChessPieceTypePawn, GameSide: SideWhite, Moved: false, PawnMovedTwoLastTime: false})
+		table.SetPosition(&ChessPiece{X: X, Y: 8, PieceType: backRankTypes[x], GameSide: SideBlack, Moved: false})
+		table.SetPosition(&ChessPiece{X: X, Y: 7, PieceType: ChessPieceTypePawn, GameSide: SideBlack, Moved: false, PawnMovedTwoLastTime: false})
+	}
+	return &table
+}
+
+// 开局的Scharnagl编号, 不是合法的Chess960开局时ok为false
+func Chess960Index(table *ChessTable) (int, bool) {
+	for i := 0; i < 960; i++ {
+		if NewChess960Table(i).SamePlacement(table) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 func MustPositionToIndex(X rune, Y int) (int, int) {
 	var x int
 	switch X {
@@ -0,0 +1,81 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCompactTable = errors.New("invalid compact table")
+
+// 紧凑的棋盘编码, 用于网络传输, 由空格分开的三段组成:
+// FEN的棋子摆放, Moved的位掩码, PawnMovedTwoLastTime的位掩码
+// 位掩码是16位十六进制数, 第i位对应数组下标i的格子
+// 比如开始局面是 rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR 0000000000000000 0000000000000000
+// 棋盘上有升变来的棋子时, 最后再加上Promoted的位掩码, 这样以前的编码仍然有效
+func EncodeTableCompact(ct *ChessTable) string {
+	var moved, movedTwo, promoted uint64
+	for i := 0; i < 64; i++ {
+		p := ct[i]
+		if p == nil {
+			continue
+		}
+		if p.Moved {
+			moved |= 1 << i
+		}
+		if p.PawnMovedTwoLastTime {
+			movedTwo |= 1 << i
+		}
+		if p.Promoted {
+			promoted |= 1 << i
+		}
+	}
+	s := fmt.Sprintf("%s %016x %016x", formatFENPlacement(ct, false), moved, movedTwo)
+	if promoted != 0 {
+		s += fmt.Sprintf(" %016x", promoted)
+	}
+	return s
+}
+
+// EncodeTableCompact的逆过程, 空格子上的状态位被当作错误
+func DecodeTableCompact(s string) (*ChessTable, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 && len(fields) != 4 {
+		return nil, fmt.Errorf("%w: expected 3 or 4 fields, got %d", ErrInvalidCompactTable, len(fields))
+	}
+
+	table, err := parseFENPlacement(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCompactTable, err)
+	}
+	moved, err := strconv.ParseUint(fields[1], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad moved mask %q", ErrInvalidCompactTable, fields[1])
+	}
+	movedTwo, err := strconv.ParseUint(fields[2], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad en passant mask %q", ErrInvalidCompactTable, fields[2])
+	}
+	var promoted uint64
+	if len(fields) == 4 {
+		if promoted, err = strconv.ParseUint(fields[3], 16, 64); err != nil {
+			return nil, fmt.Errorf("%w: bad promoted mask %q", ErrInvalidCompactTable, fields[3])
+		}
+	}
+
+	for i := 0; i < 64; i++ {
+		p := table[i]
+		bit := uint64(1) << i
+		if p == nil {
+			if (moved|movedTwo|promoted)&bit != 0 {
+				return nil, fmt.Errorf("%w: state bit on empty square %d", ErrInvalidCompactTable, i)
+			}
+			continue
+		}
+		p.Moved = moved&bit != 0
+		p.PawnMovedTwoLastTime = movedTwo&bit != 0
+		p.Promoted = promoted&bit != 0
+	}
+	return table, nil
+}
@@ -0,0 +1,88 @@
+package chess
+
+import (
+	"errors"
+	"testing"
+)
+
+func samePieces(a *ChessTable, b *ChessTable) bool {
+	for i := 0; i < 64; i++ {
+		if (a[i] == nil) != (b[i] == nil) {
+			return false
+		}
+		if a[i] != nil && *a[i] != *b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func checkCompactRoundTrip(t *testing.T, ct *ChessTable) {
+	t.Helper()
+	s := EncodeTableCompact(ct)
+	back, err := DecodeTableCompact(s)
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	if !samePieces(ct, back) {
+		t.Fatalf("%q decoded to a different table", s)
+	}
+	if again := EncodeTableCompact(back); again != s {
+		t.Fatalf("encoded %q, then %q", s, again)
+	}
+}
+
+func TestTableCompactRoundTrip(t *testing.T) {
+	start := NewChessTable()
+	if got, want := EncodeTableCompact(start), "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR 0000000000000000 0000000000000000"; got != want {
+		t.Errorf("start table %q, want %q", got, want)
+	}
+
+	// 刚走了两格的兵, 以及升变来的后和马
+	p, err := ParsePositionFEN("r3k2r/8/8/3pP3/8/8/8/RQ~2K1N~1[] w - d6 0 1")
+	if err != nil {
+		t.Fatalf("parse fen: %v", err)
+	}
+	ct := p.Table
+	if ct.GetPosition('d', 5).PawnMovedTwoLastTime != true || ct.GetPosition('b', 1).Promoted != true {
+		t.Fatalf("fen did not set en passant and promoted flags")
+	}
+	checkCompactRoundTrip(t, ct)
+
+	// 随机对局中的每个局面, Crazyhouse里的升变和打入会产生各种状态位
+	for _, v := range []Variant{VariantStandard, VariantCrazyhouse} {
+		for seed := int64(1); seed <= 5; seed++ {
+			g := randomGame(v, seed, 200)
+			for ply := 0; ply <= len(g.Moves); ply++ {
+				checkCompactRoundTrip(t, g.PositionAt(ply).Table)
+			}
+		}
+	}
+}
+
+func TestDecodeTableCompactErrors(t *testing.T) {
+	const placement = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR"
+	cases := []struct {
+		name string
+		s    string
+	}{
+		{"empty", ""},
+		{"too few fields", placement + " 0000000000000000"},
+		{"too many fields", placement + " 0 0 0 0"},
+		{"bad placement", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP 0 0"},
+		{"bad moved mask", placement + " xyz 0"},
+		{"bad en passant mask", placement + " 0 -1"},
+		{"bad promoted mask", placement + " 0 0 0x10"},
+		{"moved bit on empty square", placement + " 0000000000100000 0"},
+		{"en passant bit on empty square", placement + " 0 0000000100000000"},
+		{"promoted bit on empty square", placement + " 0 0 0000010000000000"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := DecodeTableCompact(c.s); !errors.Is(err, ErrInvalidCompactTable) {
+				t.Errorf("got error %v, want %v", err, ErrInvalidCompactTable)
+			}
+		})
+	}
+}
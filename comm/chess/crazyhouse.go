@@ -0,0 +1,161 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Crazyhouse中一方手里可以打入的棋子数量, 下标是ChessPieceType, 王的数量总是0
+type Pocket [6]int
+
+// 双方手里的棋子, 下标是Side
+type Pockets [2]Pocket
+
+// 可以打入的棋子, 生成着法和显示时都按照这个顺序
+var dropPieceTypes = [5]ChessPieceType{ChessPieceTypeQueen, ChessPieceTypeRook, ChessPieceTypeBishop, ChessPieceTypeKnight, ChessPieceTypePawn}
+
+// 手里一共有几个棋子
+func (pk *Pocket) Count() int {
+	n := 0
+	for _, c := range pk {
+		n += c
+	}
+	return n
+}
+
+// 被吃掉的棋子进入吃子一方的手里, 升变来的棋子变回兵
+func pocketPieceType(captured *ChessPiece) ChessPieceType {
+	if captured.Promoted {
+		return ChessPieceTypePawn
+	}
+	return captured.PieceType
+}
+
+// side方手里多一个t, 返回哈希的变化
+func (pks *Pockets) put(side Side, t ChessPieceType) uint64 {
+	h := zobristPocket(side, t, pks[side][t])
+	pks[side][t]++
+	return h
+}
+
+// side方手里少一个t, 返回哈希的变化
+func (pks *Pockets) take(side Side, t ChessPieceType) uint64 {
+	pks[side][t]--
+	return zobristPocket(side, t, pks[side][t])
+}
+
+func (pks *Pockets) hash() uint64 {
+	var h uint64
+	for side := 0; side < 2; side++ {
+		for t := 0; t < 6; t++ {
+			for n := 0; n < pks[side][t]; n++ {
+				h ^= zobristPocket(Side(side), ChessPieceType(t), n)
+			}
+		}
+	}
+	return h
+}
+
+// FEN中方括号里的写法, 白方大写在前, 黑方小写在后, 比如QNnp
+func (pks *Pockets) String() string {
+	var sb strings.Builder
+	for _, side := range [2]Side{SideWhite, SideBlack} {
+		for _, t := range dropPieceTypes {
+			letter := string(pieceTypeLetter(t))
+			if side == SideBlack {
+				letter = strings.ToLower(letter)
+			}
+			sb.WriteString(strings.Repeat(letter, pks[side][t]))
+		}
+	}
+	return sb.String()
+}
+
+func parseFENPockets(s string) (Pockets, error) {
+	var pks Pockets
+	for _, r := range s {
+		t, ok := letterToPieceType(r)
+		if !ok || t == ChessPieceTypeKing {
+			return Pockets{}, fmt.Errorf("%w: bad pocket piece %q", ErrInvalidFEN, r)
+		}
+		side := SideWhite
+		if r >= 'a' {
+			side = SideBlack
+		}
+		pks[side][t]++
+	}
+	return pks, nil
+}
+
+// side方所有合法的打入, 兵不能打入第一行和第八行
+// 没有被将军时打入不会让自己的王暴露, 被将军时只有挡住将军的打入才合法
+func (ct *ChessTable) appendDrops(moves []Move, side Side, pocket *Pocket) []Move {
+	if pocket.Count() == 0 {
+		return moves
+	}
+	inCheck := ct.InCheck(side)
+	for i := 0; i < 64; i++ {
+		if ct[i] != nil {
+			continue
+		}
+		x, y := i%8, i/8
+		if inCheck {
+			ct[i] = &ChessPiece{PieceType: ChessPieceTypeKnight, GameSide: side}
+			blocked := !ct.InCheck(side)
+			ct[i] = nil
+			if !blocked {
+				continue
+			}
+		}
+		for _, t := range dropPieceTypes {
+			if pocket[t] == 0 || (t == ChessPieceTypePawn && (y == 0 || y == 7)) {
+				continue
+			}
+			m := Move{Type: MoveTypeDrop, Drop: t}
+			m.ToX, m.ToY = indexToPosition(x, y)
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// 把side方的一个棋子放到空格子上, 打入的棋子当作已经移动过, 不能用来易位
+func (ct *ChessTable) makeDrop(m Move, side Side) Undo {
+	u := Undo{Move: m}
+	u.clearedEnPassant = ct.clearEnPassant()
+	u.piece = &ChessPiece{PieceType: m.Drop, X: m.ToX, Y: m.ToY, GameSide: side, Moved: true}
+	ct.SetPosition(u.piece)
+	return u
+}
+
+func (ct *ChessTable) unmakeDrop(u Undo) {
+	ct.ClearPosition(u.Move.ToX, u.Move.ToY)
+	ct.restoreEnPassant(u.clearedEnPassant)
+}
+
+// 执行一步打入, u中已经保存了局面原来的字段
+func (p *Position) makeDrop(m Move, u PositionUndo) PositionUndo {
+	x, y := MustPositionToIndex(m.ToX, m.ToY)
+
+	h := p.Hash
+	h ^= zobristEnPassantKey(p.Table, p.SideToMove, p.EnPassantX, p.EnPassantY)
+	u.Undo = p.Table.makeDrop(m, p.SideToMove)
+	h ^= zobristPiece(u.piece, y*8+x)
+	h ^= p.Pockets.take(p.SideToMove, m.Drop)
+
+	// 打入兵和走兵一样是不可逆的
+	p.EnPassantX, p.EnPassantY = 0, 0
+	if m.Drop == ChessPieceTypePawn {
+		p.HalfmoveClock = 0
+	} else {
+		p.HalfmoveClock++
+	}
+	if p.SideToMove == SideBlack {
+		p.FullmoveNumber++
+	}
+	p.SideToMove = p.SideToMove.Opponent()
+
+	h ^= zobristBlackMove
+	p.Hash = h
+	return u
+}
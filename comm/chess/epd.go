@@ -0,0 +1,253 @@
+package chess
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidEPD = errors.New("invalid epd")
+
+// EPD中的一个操作, 比如bm Nf3 e4;
+type EPDOperation struct {
+	Opcode   string
+	Operands []string
+}
+
+// 一行EPD, 局面加上若干操作
+type EPD struct {
+	Position   *Position
+	Operations []EPDOperation
+}
+
+// 解析一行EPD, 前四个字段和FEN相同, 之后是以分号结尾的操作
+// hmvc和fmvn两个操作会同时设置局面的计数器
+func ParseEPD(line string) (*EPD, error) {
+	rest := strings.TrimSpace(line)
+	fields := make([]string, 0, 4)
+	for len(fields) < 4 {
+		rest = strings.TrimLeft(rest, " \t")
+		i := strings.IndexAny(rest, " \t")
+		if i < 0 {
+			if rest == "" {
+				break
+			}
+			i = len(rest)
+		}
+		fields = append(fields, rest[:i])
+		rest = rest[i:]
+	}
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("%w: expected 4 position fields", ErrInvalidEPD)
+	}
+
+	pos, err := ParsePositionFEN(strings.Join(fields, " "))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEPD, err)
+	}
+
+	// 有些perft测试集在四个字段之后还带着FEN的两个计数器, 后面可能紧跟着第一个操作
+	// 操作码不会是数字, 所以开头两个都是数字时就是计数器
+	if counters := strings.Fields(strings.SplitN(rest, ";", 2)[0]); len(counters) >= 2 {
+		halfmove, err1 := strconv.Atoi(counters[0])
+		fullmove, err2 := strconv.Atoi(counters[1])
+		if err1 == nil && err2 == nil {
+			pos.HalfmoveClock, pos.FullmoveNumber = halfmove, fullmove
+			rest = strings.TrimLeft(rest, " \t")[len(counters[0]):]
+			rest = strings.TrimLeft(rest, " \t")[len(counters[1]):]
+		}
+	}
+
+	ops, err := parseEPDOperations(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &EPD{Position: pos, Operations: ops}
+	if v, ok := e.intOperand("hmvc"); ok {
+		pos.HalfmoveClock = v
+	}
+	if v, ok := e.intOperand("fmvn"); ok {
+		pos.FullmoveNumber = v
+	}
+	return e, nil
+}
+
+func parseEPDOperations(s string) ([]EPDOperation, error) {
+	var ops []EPDOperation
+	src := []rune(s)
+	i := 0
+
+	for {
+		for i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == ';') {
+			i++
+		}
+		if i >= len(src) {
+			return ops, nil
+		}
+
+		start := i
+		for i < len(src) && src[i] != ' ' && src[i] != '\t' && src[i] != ';' {
+			i++
+		}
+		op := EPDOperation{Opcode: string(src[start:i])}
+
+		// 操作数直到分号为止, 可能是带引号的字符串, 字符串中用\\和\"表示反斜杠和引号
+		for {
+			for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+				i++
+			}
+			if i >= len(src) || src[i] == ';' {
+				break
+			}
+			if src[i] == '"' {
+				var sb strings.Builder
+				i++
+				for i < len(src) && src[i] != '"' {
+					if src[i] == '\\' && i+1 < len(src) {
+						i++
+					}
+					sb.WriteRune(src[i])
+					i++
+				}
+				if i >= len(src) {
+					return nil, fmt.Errorf("%w: unterminated string in %s", ErrInvalidEPD, op.Opcode)
+				}
+				op.Operands = append(op.Operands, sb.String())
+				i++
+				continue
+			}
+			start := i
+			for i < len(src) && src[i] != ' ' && src[i] != '\t' && src[i] != ';' {
+				i++
+			}
+			op.Operands = append(op.Operands, string(src[start:i]))
+		}
+
+		ops = append(ops, op)
+	}
+}
+
+// 读取EPD文件, 跳过空行和以#开头的行
+func ReadEPD(r io.Reader) ([]*EPD, error) {
+	var epds []*EPD
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e, err := ParseEPD(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		epds = append(epds, e)
+	}
+	return epds, scanner.Err()
+}
+
+// 某个操作的操作数, 操作不存在时ok为false
+func (e *EPD) Operands(opcode string) ([]string, bool) {
+	for _, op := range e.Operations {
+		if op.Opcode == opcode {
+			return op.Operands, true
+		}
+	}
+	return nil, false
+}
+
+func (e *EPD) stringOperand(opcode string) string {
+	operands, ok := e.Operands(opcode)
+	if !ok || len(operands) == 0 {
+		return ""
+	}
+	return operands[0]
+}
+
+func (e *EPD) intOperand(opcode string) (int, bool) {
+	v, err := strconv.Atoi(e.stringOperand(opcode))
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// id操作, 测试局面的名字
+func (e *EPD) ID() string {
+	return e.stringOperand("id")
+}
+
+// c0到c9的注释
+func (e *EPD) Comment(n int) string {
+	return e.stringOperand(fmt.Sprintf("c%d", n))
+}
+
+func (e *EPD) sanOperands(opcode string) ([]Move, error) {
+	operands, _ := e.Operands(opcode)
+	moves := make([]Move, 0, len(operands))
+	for _, s := range operands {
+		m, err := e.Position.Table.ParseSAN(s, e.Position.SideToMove)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidEPD, opcode, err)
+		}
+		moves = append(moves, m)
+	}
+	return moves, nil
+}
+
+// bm操作, 最佳着法
+func (e *EPD) BestMoves() ([]Move, error) {
+	return e.sanOperands("bm")
+}
+
+// am操作, 应该避免的着法
+func (e *EPD) AvoidMoves() ([]Move, error) {
+	return e.sanOperands("am")
+}
+
+// perft测试用的D1到D6操作, 键是深度, 值是节点数
+func (e *EPD) PerftNodes() map[int]uint64 {
+	nodes := make(map[int]uint64)
+	for _, op := range e.Operations {
+		if len(op.Opcode) != 2 || op.Opcode[0] != 'D' || op.Opcode[1] < '1' || op.Opcode[1] > '9' || len(op.Operands) == 0 {
+			continue
+		}
+		n, err := strconv.ParseUint(op.Operands[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		nodes[int(op.Opcode[1]-'0')] = n
+	}
+	return nodes
+}
+
+// 导出成一行EPD
+func (e *EPD) String() string {
+	fen := strings.Fields(e.Position.FEN())
+	var sb strings.Builder
+	sb.WriteString(strings.Join(fen[:4], " "))
+	for _, op := range e.Operations {
+		sb.WriteByte(' ')
+		sb.WriteString(op.Opcode)
+		for _, operand := range op.Operands {
+			sb.WriteByte(' ')
+			if isEPDStringOpcode(op.Opcode) || strings.ContainsAny(operand, " \t;\"") || operand == "" {
+				sb.WriteString(`"` + escapePGNString(operand) + `"`)
+			} else {
+				sb.WriteString(operand)
+			}
+		}
+		sb.WriteByte(';')
+	}
+	return sb.String()
+}
+
+// id和c0到c9的操作数习惯上总是带引号
+func isEPDStringOpcode(opcode string) bool {
+	return opcode == "id" || (len(opcode) == 2 && opcode[0] == 'c' && opcode[1] >= '0' && opcode[1] <= '9')
+}
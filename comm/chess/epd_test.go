@@ -0,0 +1,157 @@
+package chess
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const epdStart = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -"
+
+func TestParseEPD(t *testing.T) {
+	cases := []struct {
+		name     string
+		line     string
+		ops      []EPDOperation
+		halfmove int
+		fullmove int
+	}{
+		{
+			name:     "no operations",
+			line:     epdStart,
+			halfmove: 0,
+			fullmove: 1,
+		},
+		{
+			name:     "fen counters without operations",
+			line:     epdStart + " 3 17",
+			halfmove: 3,
+			fullmove: 17,
+		},
+		{
+			name:     "fen counters before operations",
+			line:     epdStart + " 5 12 bm e4; id \"start\";",
+			ops:      []EPDOperation{{"bm", []string{"e4"}}, {"id", []string{"start"}}},
+			halfmove: 5,
+			fullmove: 12,
+		},
+		{
+			name:     "hmvc and fmvn",
+			line:     epdStart + " hmvc 7; fmvn 30;",
+			ops:      []EPDOperation{{"hmvc", []string{"7"}}, {"fmvn", []string{"30"}}},
+			halfmove: 7,
+			fullmove: 30,
+		},
+		{
+			name:     "quoted operands",
+			line:     epdStart + ` id "a;b"; c0 "two words" "say \"hi\"";`,
+			ops:      []EPDOperation{{"id", []string{"a;b"}}, {"c0", []string{"two words", `say "hi"`}}},
+			fullmove: 1,
+		},
+		{
+			name: "perft depths",
+			line: epdStart + " ;D1 20 ;D2 400 ;D3 8902",
+			ops: []EPDOperation{
+				{"D1", []string{"20"}},
+				{"D2", []string{"400"}},
+				{"D3", []string{"8902"}},
+			},
+			fullmove: 1,
+		},
+		{
+			name:     "operation without operands",
+			line:     epdStart + " noop; bm Nf3 e4;",
+			ops:      []EPDOperation{{Opcode: "noop"}, {"bm", []string{"Nf3", "e4"}}},
+			fullmove: 1,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			e, err := ParseEPD(c.line)
+			if err != nil {
+				t.Fatalf("parse epd: %v", err)
+			}
+			if !reflect.DeepEqual(e.Operations, c.ops) {
+				t.Errorf("operations %q, want %q", e.Operations, c.ops)
+			}
+			if e.Position.HalfmoveClock != c.halfmove || e.Position.FullmoveNumber != c.fullmove {
+				t.Errorf("counters %d %d, want %d %d", e.Position.HalfmoveClock, e.Position.FullmoveNumber, c.halfmove, c.fullmove)
+			}
+
+			// 导出之后再读回来, 局面和操作都不变
+			back, err := ParseEPD(e.String())
+			if err != nil {
+				t.Fatalf("parse %q: %v", e.String(), err)
+			}
+			if !reflect.DeepEqual(back.Operations, e.Operations) {
+				t.Errorf("round trip %q: operations %q, want %q", e.String(), back.Operations, e.Operations)
+			}
+			if back.Position.Hash != e.Position.Hash {
+				t.Errorf("round trip %q: position changed", e.String())
+			}
+		})
+	}
+}
+
+func TestEPDOperands(t *testing.T) {
+	e, err := ParseEPD(epdStart + ` bm Nf3 e4; am f3; id "x"; c3 "note"; D1 20; D2 400;`)
+	if err != nil {
+		t.Fatalf("parse epd: %v", err)
+	}
+	if e.ID() != "x" || e.Comment(3) != "note" || e.Comment(0) != "" {
+		t.Errorf("id %q, c3 %q, c0 %q", e.ID(), e.Comment(3), e.Comment(0))
+	}
+	if bm, err := e.BestMoves(); err != nil || len(bm) != 2 || bm[0].String() != "g1f3" || bm[1].String() != "e2e4" {
+		t.Errorf("best moves %v, %v", bm, err)
+	}
+	if am, err := e.AvoidMoves(); err != nil || len(am) != 1 || am[0].String() != "f2f3" {
+		t.Errorf("avoid moves %v, %v", am, err)
+	}
+	if got, want := e.PerftNodes(), map[int]uint64{1: 20, 2: 400}; !reflect.DeepEqual(got, want) {
+		t.Errorf("perft nodes %v, want %v", got, want)
+	}
+}
+
+// 带引号和反斜杠的操作数导出之后还能原样读回来
+func TestEPDStringEscapes(t *testing.T) {
+	e, err := ParseEPD(epdStart)
+	if err != nil {
+		t.Fatalf("parse epd: %v", err)
+	}
+	e.Operations = []EPDOperation{
+		{"c0", []string{`he said "Nf3!"`, `back\slash`}},
+		{"xx", []string{`a"b`}},
+	}
+	back, err := ParseEPD(e.String())
+	if err != nil {
+		t.Fatalf("parse %q: %v", e.String(), err)
+	}
+	if !reflect.DeepEqual(back.Operations, e.Operations) {
+		t.Errorf("%q: operations %q, want %q", e.String(), back.Operations, e.Operations)
+	}
+}
+
+func TestParseEPDErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		line string
+	}{
+		{"too few fields", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq"},
+		{"bad placement", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN w KQkq -"},
+		{"unterminated string", epdStart + ` id "never closed;`},
+		{"illegal best move", epdStart + " bm e5;"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			e, err := ParseEPD(c.line)
+			if err == nil {
+				_, err = e.BestMoves()
+			}
+			if !errors.Is(err, ErrInvalidEPD) {
+				t.Errorf("got error %v, want %v", err, ErrInvalidEPD)
+			}
+		})
+	}
+}
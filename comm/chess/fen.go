@@ -0,0 +1,365 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 标准开局的FEN
+const StartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+var ErrInvalidFEN = errors.New("invalid fen")
+
+// 棋子对应的大写字母, 比如车是R, 马是N
+func pieceTypeLetter(t ChessPieceType) rune {
+	switch t {
+	case ChessPieceTypeRook:
+		return 'R'
+	case ChessPieceTypeKnight:
+		return 'N'
+	case ChessPieceTypeBishop:
+		return 'B'
+	case ChessPieceTypeQueen:
+		return 'Q'
+	case ChessPieceTypeKing:
+		return 'K'
+	case ChessPieceTypePawn:
+		return 'P'
+	default:
+		panic("unreachable")
+	}
+}
+
+func letterToPieceType(r rune) (ChessPieceType, bool) {
+	switch r {
+	case 'R', 'r':
+		return ChessPieceTypeRook, true
+	case 'N', 'n':
+		return ChessPieceTypeKnight, true
+	case 'B', 'b':
+		return ChessPieceTypeBishop, true
+	case 'Q', 'q':
+		return ChessPieceTypeQueen, true
+	case 'K', 'k':
+		return ChessPieceTypeKing, true
+	case 'P', 'p':
+		return ChessPieceTypePawn, true
+	}
+	return 0, false
+}
+
+// FEN中的棋子字母, 白方大写, 黑方小写
+func pieceFENLetter(p *ChessPiece) rune {
+	r := pieceTypeLetter(p.PieceType)
+	if p.GameSide == SideBlack {
+		r += 'a' - 'A'
+	}
+	return r
+}
+
+// 棋子是否还在开局时的位置上, 用来推断Moved
+func onStartSquare(p *ChessPiece, x int, y int) bool {
+	switch p.PieceType {
+	case ChessPieceTypePawn:
+		return y == backRank(p.GameSide)+pawnDirection(p.GameSide)
+	case ChessPieceTypeRook:
+		return y == backRank(p.GameSide) && (x == 0 || x == 7)
+	case ChessPieceTypeKnight:
+		return y == backRank(p.GameSide) && (x == 1 || x == 6)
+	case ChessPieceTypeBishop:
+		return y == backRank(p.GameSide) && (x == 2 || x == 5)
+	case ChessPieceTypeQueen:
+		return y == backRank(p.GameSide) && x == 3
+	case ChessPieceTypeKing:
+		return y == backRank(p.GameSide) && x == 4
+	}
+	return false
+}
+
+// 解析FEN, 返回棋盘, 轮到哪一方走, 半回合计数和回合数
+// 王车易位的权利会被转换成王和车的Moved, 过路兵的格子会被转换成兵的PawnMovedTwoLastTime
+// 缺少最后两个计数字段时, 分别按0和1处理
+func ParseFEN(fen string) (table *ChessTable, sideToMove Side, halfmove int, fullmove int, err error) {
+	fields := strings.Fields(fen)
+	if len(fields) != 4 && len(fields) != 6 {
+		return nil, 0, 0, 0, fmt.Errorf("%w: expected 4 or 6 fields, got %d", ErrInvalidFEN, len(fields))
+	}
+
+	table, err = parseFENPlacement(fields[0])
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+
+	switch fields[1] {
+	case "w":
+		sideToMove = SideWhite
+	case "b":
+		sideToMove = SideBlack
+	default:
+		return nil, 0, 0, 0, fmt.Errorf("%w: bad side to move %q", ErrInvalidFEN, fields[1])
+	}
+
+	if err := applyFENCastling(table, fields[2]); err != nil {
+		return nil, 0, 0, 0, err
+	}
+
+	if err := applyFENEnPassant(table, fields[3], sideToMove); err != nil {
+		return nil, 0, 0, 0, err
+	}
+
+	halfmove, fullmove = 0, 1
+	if len(fields) == 6 {
+		halfmove, err = strconv.Atoi(fields[4])
+		if err != nil || halfmove < 0 {
+			return nil, 0, 0, 0, fmt.Errorf("%w: bad halfmove clock %q", ErrInvalidFEN, fields[4])
+		}
+		fullmove, err = strconv.Atoi(fields[5])
+		if err != nil || fullmove < 1 {
+			return nil, 0, 0, 0, fmt.Errorf("%w: bad fullmove number %q", ErrInvalidFEN, fields[5])
+		}
+	}
+
+	return table, sideToMove, halfmove, fullmove, nil
+}
+
+func parseFENPlacement(placement string) (*ChessTable, error) {
+	var table ChessTable
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("%w: expected 8 ranks, got %d", ErrInvalidFEN, len(ranks))
+	}
+
+	for i, rank := range ranks {
+		y := 7 - i
+		x := 0
+		for _, r := range rank {
+			if r >= '1' && r <= '8' {
+				x += int(r - '0')
+				continue
+			}
+			// Crazyhouse中表示前一个棋子是升变来的
+			if r == '~' {
+				if x == 0 || x > 8 || table.GetIndex(x-1, y) == nil {
+					return nil, fmt.Errorf("%w: misplaced ~ on rank %d", ErrInvalidFEN, y+1)
+				}
+				table.GetIndex(x-1, y).Promoted = true
+				continue
+			}
+			t, ok := letterToPieceType(r)
+			if !ok {
+				return nil, fmt.Errorf("%w: bad piece %q", ErrInvalidFEN, r)
+			}
+			if x >= 8 {
+				return nil, fmt.Errorf("%w: rank %d is too long", ErrInvalidFEN, y+1)
+			}
+			side := SideWhite
+			if r >= 'a' {
+				side = SideBlack
+			}
+			p := &ChessPiece{PieceType: t, GameSide: side}
+			p.X, p.Y = indexToPosition(x, y)
+			p.Moved = !onStartSquare(p, x, y)
+			table.SetPosition(p)
+			x++
+		}
+		if x != 8 {
+			return nil, fmt.Errorf("%w: rank %d has %d squares", ErrInvalidFEN, y+1, x)
+		}
+	}
+
+	return &table, nil
+}
+
+// 根据易位权利设置王和车的Moved, 没有权利的王和车都当作移动过
+// 除了KQkq, 也支持Shredder-FEN和X-FEN中用车所在的列表示的权利, 比如HAha, 用于Chess960
+// KQkq表示王这一侧最外面的车
+func applyFENCastling(table *ChessTable, castling string) error {
+	for _, side := range [2]Side{SideWhite, SideBlack} {
+		y := backRank(side)
+		for x := 0; x < 8; x++ {
+			if p := table.GetIndex(x, y); p != nil && p.GameSide == side &&
+				(p.PieceType == ChessPieceTypeKing || p.PieceType == ChessPieceTypeRook) {
+				p.Moved = true
+			}
+		}
+	}
+
+	if castling == "-" {
+		return nil
+	}
+
+	for _, r := range castling {
+		side := SideWhite
+		if r >= 'a' && r <= 'z' {
+			side = SideBlack
+		}
+		y := backRank(side)
+
+		kingX := -1
+		for x := 0; x < 8; x++ {
+			if k := table.GetIndex(x, y); k != nil && k.PieceType == ChessPieceTypeKing && k.GameSide == side {
+				kingX = x
+			}
+		}
+		if kingX < 0 {
+			return fmt.Errorf("%w: castling right %c without king", ErrInvalidFEN, r)
+		}
+
+		rookX := -1
+		switch {
+		case r == 'K' || r == 'k':
+			rookX = outermostRookX(table, side, kingX, true)
+		case r == 'Q' || r == 'q':
+			rookX = outermostRookX(table, side, kingX, false)
+		case r >= 'A' && r <= 'H':
+			rookX = int(r - 'A')
+		case r >= 'a' && r <= 'h':
+			rookX = int(r - 'a')
+		default:
+			return fmt.Errorf("%w: bad castling rights %q", ErrInvalidFEN, castling)
+		}
+
+		rook := (*ChessPiece)(nil)
+		if rookX >= 0 && rookX != kingX {
+			rook = table.GetIndex(rookX, y)
+		}
+		if rook == nil || rook.PieceType != ChessPieceTypeRook || rook.GameSide != side {
+			return fmt.Errorf("%w: castling right %c without rook", ErrInvalidFEN, r)
+		}
+		table.GetIndex(kingX, y).Moved = false
+		rook.Moved = false
+	}
+	return nil
+}
+
+// 王某一侧底线上离角最近的车, 没有时返回-1
+func outermostRookX(table *ChessTable, side Side, kingX int, kingside bool) int {
+	y := backRank(side)
+	x, step := 0, 1
+	if kingside {
+		x, step = 7, -1
+	}
+	for ; x != kingX; x += step {
+		if p := table.GetIndex(x, y); p != nil && p.PieceType == ChessPieceTypeRook && p.GameSide == side {
+			return x
+		}
+	}
+	return -1
+}
+
+// FEN中的易位权利, 按照X-FEN的写法, 易位的车不是最外面的车时用它所在的列表示
+func formatFENCastling(ct *ChessTable, castling CastlingRights) string {
+	if castling == CastlingNone {
+		return "-"
+	}
+	var sb strings.Builder
+	for _, side := range [2]Side{SideWhite, SideBlack} {
+		kingX, ok := ct.unmovedKingX(side)
+		for _, kingside := range [2]bool{true, false} {
+			if castling&castlingRight(side, kingside) == 0 {
+				continue
+			}
+			r := 'K'
+			if !kingside {
+				r = 'Q'
+			}
+			if ok {
+				if rookX, found := ct.castlingRookX(side, kingX, kingside); found && rookX != outermostRookX(ct, side, kingX, kingside) {
+					r = 'A' + rune(rookX)
+				}
+			}
+			if side == SideBlack {
+				r += 'a' - 'A'
+			}
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// 过路兵的格子在刚走了两格的兵的身后
+func applyFENEnPassant(table *ChessTable, square string, sideToMove Side) error {
+	if square == "-" {
+		return nil
+	}
+
+	sq := []rune(square)
+	if len(sq) != 2 || sq[0] < 'a' || sq[0] > 'h' || sq[1] < '1' || sq[1] > '8' {
+		return fmt.Errorf("%w: bad en passant square %q", ErrInvalidFEN, square)
+	}
+
+	mover := sideToMove.Opponent()
+	x := int(sq[0] - 'a')
+	y := int(sq[1]-'1') + pawnDirection(mover)
+	if y != backRank(mover)+3*pawnDirection(mover) {
+		return fmt.Errorf("%w: bad en passant square %q", ErrInvalidFEN, square)
+	}
+
+	p := table.GetIndex(x, y)
+	if p == nil || p.PieceType != ChessPieceTypePawn || p.GameSide != mover {
+		return fmt.Errorf("%w: no pawn behind en passant square %q", ErrInvalidFEN, square)
+	}
+	p.PawnMovedTwoLastTime = true
+	return nil
+}
+
+// 导出FEN, 易位权利根据王和车的Moved推断, 过路兵的格子根据兵的PawnMovedTwoLastTime推断
+func (ct *ChessTable) FEN(sideToMove Side, halfmove int, fullmove int) string {
+	epX, epY, _ := ct.EnPassantSquare(sideToMove)
+	return formatFEN(ct, sideToMove, ct.CastlingRights(), epX, epY, halfmove, fullmove)
+}
+
+// FEN的第一个字段, 只包含棋子的摆放
+// promoted为true时在升变来的棋子后面加上~, 只用于Crazyhouse
+func formatFENPlacement(ct *ChessTable, promoted bool) string {
+	var sb strings.Builder
+	for y := 7; y >= 0; y-- {
+		empty := 0
+		for x := 0; x < 8; x++ {
+			p := ct.GetIndex(x, y)
+			if p == nil {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteRune(rune('0' + empty))
+				empty = 0
+			}
+			sb.WriteRune(pieceFENLetter(p))
+			if promoted && p.Promoted {
+				sb.WriteByte('~')
+			}
+		}
+		if empty > 0 {
+			sb.WriteRune(rune('0' + empty))
+		}
+		if y > 0 {
+			sb.WriteByte('/')
+		}
+	}
+	return sb.String()
+}
+
+// 没有过路兵的格子时epX为0
+func formatFEN(ct *ChessTable, sideToMove Side, castling CastlingRights, epX rune, epY int, halfmove int, fullmove int) string {
+	var sb strings.Builder
+	sb.WriteString(formatFENPlacement(ct, false))
+
+	if sideToMove == SideWhite {
+		sb.WriteString(" w ")
+	} else {
+		sb.WriteString(" b ")
+	}
+
+	sb.WriteString(formatFENCastling(ct, castling))
+	if epX != 0 {
+		fmt.Fprintf(&sb, " %c%d", epX, epY)
+	} else {
+		sb.WriteString(" -")
+	}
+	fmt.Fprintf(&sb, " %d %d", halfmove, fullmove)
+
+	return sb.String()
+}
@@ -0,0 +1,367 @@
+package chess
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrIllegalMove = errors.New("illegal move")
+	ErrGameOver    = errors.New("game is over")
+)
+
+type GameResult int
+
+const (
+	// 对局还在进行
+	GameResultOngoing GameResult = iota
+	GameResultWhiteWins
+	GameResultBlackWins
+	GameResultDraw
+)
+
+// 胜利的一方, 平局时返回SideBoth, 对局未结束时ok为false
+func (r GameResult) Winner() (Side, bool) {
+	switch r {
+	case GameResultWhiteWins:
+		return SideWhite, true
+	case GameResultBlackWins:
+		return SideBlack, true
+	case GameResultDraw:
+		return SideBoth, true
+	default:
+		return SideBoth, false
+	}
+}
+
+// PGN中的结果, 比如1-0
+func (r GameResult) String() string {
+	winner, ok := r.Winner()
+	if !ok {
+		return PGNResultUnknown
+	}
+	return PGNResultFromWinner(winner)
+}
+
+// 根据胜利的一方得到结果, SideBoth表示平局
+func GameResultFromWinner(winner Side) GameResult {
+	switch winner {
+	case SideWhite:
+		return GameResultWhiteWins
+	case SideBlack:
+		return GameResultBlackWins
+	default:
+		return GameResultDraw
+	}
+}
+
+type GameResultReason int
+
+const (
+	GameResultReasonNone GameResultReason = iota
+	GameResultReasonCheckmate
+	GameResultReasonStalemate
+	// 一方认输
+	GameResultReasonResignation
+	// 双方同意和棋
+	GameResultReasonAgreement
+	// 一方掉线
+	GameResultReasonDisconnection
+	GameResultReasonThreefoldRepetition
+	GameResultReasonFivefoldRepetition
+	GameResultReasonFiftyMoveRule
+	GameResultReasonSeventyFiveMoveRule
+	GameResultReasonInsufficientMaterial
+	// 王走到了中心, 只用于King of the Hill
+	GameResultReasonKingOfTheHill
+	// 第三次将军, 只用于Three-check
+	GameResultReasonThreeCheck
+	// 王被炸掉, 只用于Atomic
+	GameResultReasonExplosion
+)
+
+func (r GameResultReason) String() string {
+	switch r {
+	case GameResultReasonCheckmate:
+		return "checkmate"
+	case GameResultReasonStalemate:
+		return "stalemate"
+	case GameResultReasonResignation:
+		return "resignation"
+	case GameResultReasonAgreement:
+		return "draw by agreement"
+	case GameResultReasonDisconnection:
+		return "disconnection"
+	case GameResultReasonThreefoldRepetition:
+		return "threefold repetition"
+	case GameResultReasonFivefoldRepetition:
+		return "fivefold repetition"
+	case GameResultReasonFiftyMoveRule:
+		return "fifty-move rule"
+	case GameResultReasonSeventyFiveMoveRule:
+		return "seventy-five-move rule"
+	case GameResultReasonInsufficientMaterial:
+		return "insufficient material"
+	case GameResultReasonKingOfTheHill:
+		return "king of the hill"
+	case GameResultReasonThreeCheck:
+		return "three checks"
+	case GameResultReasonExplosion:
+		return "king exploded"
+	default:
+		return ""
+	}
+}
+
+// 和棋原因对应的结束原因
+func GameResultReasonFromDraw(r DrawReason) GameResultReason {
+	switch r {
+	case DrawReasonThreefoldRepetition:
+		return GameResultReasonThreefoldRepetition
+	case DrawReasonFivefoldRepetition:
+		return GameResultReasonFivefoldRepetition
+	case DrawReasonFiftyMoveRule:
+		return GameResultReasonFiftyMoveRule
+	case DrawReasonSeventyFiveMoveRule:
+		return GameResultReasonSeventyFiveMoveRule
+	case DrawReasonInsufficientMaterial:
+		return GameResultReasonInsufficientMaterial
+	default:
+		return GameResultReasonNone
+	}
+}
+
+// 对局中走过的一步
+type GameMove struct {
+	Move Move
+	SAN  string
+	// 走完这一步之后局面的哈希
+	Hash uint64
+	// 这一步是否将军
+	Check bool
+	Time  time.Time
+
+	undo PositionUndo
+}
+
+// 一盘对局, 包括开始局面, 所有的着法和结果
+// 悔棋, 重复局面判断和导出PGN都基于这里的记录
+type Game struct {
+	// 开始局面, 不会被修改
+	Start *Position
+	// 当前局面, 只能通过MakeMove和Takeback修改
+	Position  *Position
+	Moves     []GameMove
+	StartTime time.Time
+
+	Result GameResult
+	Reason GameResultReason
+
+	history *GameHistory
+}
+
+// 从start开始一盘新的对局, start会被复制
+func NewGame(start *Position) *Game {
+	g := &Game{
+		Start:     start.Copy(),
+		Position:  start.Copy(),
+		StartTime: time.Now(),
+	}
+	g.history = NewGameHistory(g.Position)
+	return g
+}
+
+// 按照变体v开始一盘新的对局, start会被复制, 它的Variant会被改成v
+func NewVariantGame(start *Position, v Variant) *Game {
+	start = start.Copy()
+	start.Variant = v
+	return NewGame(start)
+}
+
+// 对局使用的规则, 由开始局面决定
+func (g *Game) Variant() Variant {
+	return g.Start.Variant
+}
+
+func (g *Game) SideToMove() Side {
+	return g.Position.SideToMove
+}
+
+func (g *Game) IsOver() bool {
+	return g.Result != GameResultOngoing
+}
+
+// 走一步, 记录下来并自动判定将死和逼和
+func (g *Game) MakeMove(m Move) error {
+	if g.IsOver() {
+		return ErrGameOver
+	}
+
+	legal := false
+	for _, lm := range g.Position.LegalMoves() {
+		if lm == m {
+			legal = true
+			break
+		}
+	}
+	if !legal {
+		return ErrIllegalMove
+	}
+
+	san := g.Position.MoveToSAN(m)
+	undo := g.Position.MakeMove(m)
+	g.Moves = append(g.Moves, GameMove{
+		Move:  m,
+		SAN:   san,
+		Hash:  g.Position.Hash,
+		Check: g.Position.InCheck(),
+		Time:  time.Now(),
+		undo:  undo,
+	})
+	// 将军次数由Moves算出, 所以要在追加着法之后记录
+	g.history.Push(g.historyHash(), g.Position.HalfmoveClock)
+
+	g.updateResult()
+	return nil
+}
+
+// 用标准代数记法走一步
+func (g *Game) MakeMoveSAN(san string) error {
+	m, err := g.Position.ParseSAN(san)
+	if err != nil {
+		return err
+	}
+	return g.MakeMove(m)
+}
+
+// 先判定变体特有的结束条件, 然后是将死和逼和, 之后没有合法的着法, 直接结束对局
+// 强制和棋不在这里判定, 由调用方通过ForcedDraw决定, 因为服务端不一定实现了这些规则
+func (g *Game) updateResult() {
+	if result, reason := g.Variant().Rules().Outcome(g); result != GameResultOngoing {
+		g.SetResult(result, reason)
+		return
+	}
+
+	switch g.Position.Status() {
+	case GameStatusCheckmate:
+		g.SetResult(GameResultFromWinner(g.SideToMove().Opponent()), GameResultReasonCheckmate)
+	case GameStatusStalemate:
+		g.SetResult(GameResultDraw, GameResultReasonStalemate)
+	}
+}
+
+// 悔棋, 撤销最后一步, 结果会重新变成进行中, 没有着法时返回false
+func (g *Game) Takeback() bool {
+	if len(g.Moves) == 0 {
+		return false
+	}
+	last := g.Moves[len(g.Moves)-1]
+	g.Position.UnmakeMove(last.undo)
+	g.history.Pop()
+	g.Moves = g.Moves[:len(g.Moves)-1]
+	g.Result, g.Reason = GameResultOngoing, GameResultReasonNone
+	return true
+}
+
+// 设置对局结果, 比如认输或者同意和棋, 这些无法从局面上判定
+func (g *Game) SetResult(result GameResult, reason GameResultReason) {
+	g.Result, g.Reason = result, reason
+}
+
+// side方一共将军了几次
+func (g *Game) Checks(side Side) int {
+	n := 0
+	for i, m := range g.Moves {
+		// 第i步是谁走的取决于开始局面轮到谁
+		mover := g.Start.SideToMove
+		if i%2 == 1 {
+			mover = mover.Opponent()
+		}
+		if m.Check && mover == side {
+			n++
+		}
+	}
+	return n
+}
+
+// 记录到对局历史里的哈希
+// Three-check中局面的哈希不包括将军次数, 这里混进去, 将军次数不同的局面不算重复
+func (g *Game) historyHash() uint64 {
+	h := g.Position.Hash
+	if g.Variant() == VariantThreeCheck {
+		h ^= zobristCheckCount(SideWhite, g.Checks(SideWhite))
+		h ^= zobristCheckCount(SideBlack, g.Checks(SideBlack))
+	}
+	return h
+}
+
+// 当前局面一共出现了几次
+func (g *Game) RepetitionCount() int {
+	return g.history.RepetitionCount()
+}
+
+// 当前可以申请的和棋, 没有时返回DrawReasonNone
+func (g *Game) ClaimableDraw() DrawReason {
+	return g.history.ClaimableDraw()
+}
+
+// 当前局面按规则应该直接判和的原因, 没有时返回DrawReasonNone
+func (g *Game) ForcedDraw() DrawReason {
+	if r := g.history.ForcedDraw(); r != DrawReasonNone {
+		return r
+	}
+	if g.insufficientMaterial() {
+		return DrawReasonInsufficientMaterial
+	}
+	return DrawReasonNone
+}
+
+// 按照对局的变体判断双方是否都不可能获胜
+func (g *Game) insufficientMaterial() bool {
+	switch g.Variant() {
+	case VariantCrazyhouse:
+		// 吃掉的棋子还能打入, 子力不会减少
+		return false
+	case VariantKingOfTheHill:
+		// 只剩王也可以走到中心获胜
+		return false
+	case VariantThreeCheck:
+		// 任何一个棋子都还能将军, 只有双方都只剩王时才是死局面
+		return g.Position.Table.IsBareKings()
+	case VariantAtomic:
+		// 吃掉王旁边的棋子就能把王炸掉, 王又不能吃子, 所以只有双方都只剩王时才是死局面
+		return g.Position.Table.IsBareKings()
+	default:
+		return g.Position.Table.IsInsufficientMaterial()
+	}
+}
+
+// 走完前ply步之后的局面, 0是开始局面, 返回的局面可以随意修改
+func (g *Game) PositionAt(ply int) *Position {
+	if ply < 0 || ply > len(g.Moves) {
+		return nil
+	}
+	p := g.Start.Copy()
+	for _, m := range g.Moves[:ply] {
+		p.MakeMove(m.Move)
+	}
+	return p
+}
+
+// 导出成PGN, 开始局面不是变体的标准开局或者是Chess960时会带上FEN标签
+func (g *Game) PGN() *PGNGame {
+	pg := NewPGNGame()
+	pg.SetTag("Date", g.StartTime.Format("2006.01.02"))
+	pg.SetTag("Result", g.Result.String())
+	if g.Variant() != VariantStandard {
+		pg.SetTag("Variant", g.Variant().String())
+	}
+	if fen := g.Start.FEN(); fen != g.Variant().startFEN() || g.Variant() == VariantChess960 {
+		pg.SetTag("SetUp", "1")
+		pg.SetTag("FEN", fen)
+	}
+	for _, m := range g.Moves {
+		pg.Moves = append(pg.Moves, m.SAN)
+	}
+	return pg
+}
@@ -0,0 +1,79 @@
+package chess
+
+import "testing"
+
+func newTestGame(t *testing.T, fen string, v Variant) *Game {
+	t.Helper()
+	p, err := ParsePositionFEN(fen)
+	if err != nil {
+		t.Fatalf("parse fen: %v", err)
+	}
+	return NewVariantGame(p, v)
+}
+
+func TestForcedDrawInsufficientMaterial(t *testing.T) {
+	cases := []struct {
+		name    string
+		fen     string
+		variant Variant
+		want    DrawReason
+	}{
+		{"standard bare kings", "8/8/4k3/8/8/3K4/8/8 w - - 0 1", VariantStandard, DrawReasonInsufficientMaterial},
+		{"standard knight", "8/8/4k3/8/8/3K4/8/6N1 w - - 0 1", VariantStandard, DrawReasonInsufficientMaterial},
+		{"king of the hill bare kings", "8/8/4k3/8/8/3K4/8/8 w - - 0 1", VariantKingOfTheHill, DrawReasonNone},
+		{"three-check knight", "8/8/4k3/8/8/3K4/8/6N1 w - - 0 1", VariantThreeCheck, DrawReasonNone},
+		{"three-check bare kings", "8/8/4k3/8/8/3K4/8/8 w - - 0 1", VariantThreeCheck, DrawReasonInsufficientMaterial},
+		{"atomic bishops", "8/8/4k3/3b4/2B5/8/8/K7 w - - 0 1", VariantAtomic, DrawReasonNone},
+		{"atomic bare kings", "8/8/4k3/8/8/3K4/8/8 w - - 0 1", VariantAtomic, DrawReasonInsufficientMaterial},
+		{"crazyhouse bare kings", "8/8/4k3/8/8/3K4/8/8[] w - - 0 1", VariantCrazyhouse, DrawReasonNone},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			g := newTestGame(t, c.fen, c.variant)
+			if got := g.ForcedDraw(); got != c.want {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+// 每一轮白方将军一次, 然后回到原来的局面
+func TestThreeCheckRepetitionCountsChecks(t *testing.T) {
+	moves := []string{"Rh8+", "Kd7", "Rh1", "Ke8", "Rh8+", "Kd7", "Rh1", "Ke8"}
+	for _, c := range []struct {
+		variant      Variant
+		want         int
+		wantTakeback int
+	}{
+		{VariantStandard, 3, 2},
+		{VariantThreeCheck, 1, 1},
+	} {
+		g := newTestGame(t, "4k3/8/8/8/8/8/8/4K2R w - - 0 1", c.variant)
+		for _, san := range moves {
+			if err := g.MakeMoveSAN(san); err != nil {
+				t.Fatalf("%v: %s: %v", c.variant, san, err)
+			}
+		}
+		if got := g.RepetitionCount(); got != c.want {
+			t.Errorf("%v: repetition count %d, want %d", c.variant, got, c.want)
+		}
+		for i := 0; i < 4; i++ {
+			g.Takeback()
+		}
+		if got := g.RepetitionCount(); got != c.wantTakeback {
+			t.Errorf("%v: after takeback repetition count %d, want %d", c.variant, got, c.wantTakeback)
+		}
+	}
+}
+
+// Atomic中吃掉王旁边的象就把王炸掉了, 不能当作子力不足
+func TestAtomicBishopsExplodeKing(t *testing.T) {
+	g := newTestGame(t, "8/8/4k3/3b4/2B5/8/8/K7 w - - 0 1", VariantAtomic)
+	if err := g.MakeMoveSAN("Bxd5"); err != nil {
+		t.Fatal(err)
+	}
+	if g.Result != GameResultWhiteWins || g.Reason != GameResultReasonExplosion {
+		t.Errorf("result %v, reason %v", g.Result, g.Reason)
+	}
+}
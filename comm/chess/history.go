@@ -0,0 +1,104 @@
+package chess
+
+type DrawReason int
+
+const (
+	DrawReasonNone DrawReason = iota
+	// 同一局面出现三次, 可以申请和棋
+	DrawReasonThreefoldRepetition
+	// 同一局面出现五次, 强制和棋
+	DrawReasonFivefoldRepetition
+	// 50回合没有吃子和动兵, 可以申请和棋
+	DrawReasonFiftyMoveRule
+	// 75回合没有吃子和动兵, 强制和棋
+	DrawReasonSeventyFiveMoveRule
+	// 双方都没有足够的子力将死对方, 强制和棋
+	DrawReasonInsufficientMaterial
+)
+
+// 对局中每个局面的哈希和半回合计数, 用来判断重复局面和50步规则
+// 第一个记录是开始局面, 之后每走一步记录一次
+type GameHistory struct {
+	hashes         []uint64
+	halfmoveClocks []int
+}
+
+// 以p作为开始局面
+func NewGameHistory(p *Position) *GameHistory {
+	h := &GameHistory{}
+	h.PushPosition(p)
+	return h
+}
+
+// 记录走完一步后的局面
+func (h *GameHistory) Push(hash uint64, halfmoveClock int) {
+	h.hashes = append(h.hashes, hash)
+	h.halfmoveClocks = append(h.halfmoveClocks, halfmoveClock)
+}
+
+func (h *GameHistory) PushPosition(p *Position) {
+	h.Push(p.Hash, p.HalfmoveClock)
+}
+
+// 撤销最后一次记录, 悔棋的时候用
+func (h *GameHistory) Pop() {
+	if len(h.hashes) == 0 {
+		return
+	}
+	h.hashes = h.hashes[:len(h.hashes)-1]
+	h.halfmoveClocks = h.halfmoveClocks[:len(h.halfmoveClocks)-1]
+}
+
+// 记录的局面个数
+func (h *GameHistory) Len() int {
+	return len(h.hashes)
+}
+
+// 当前局面一共出现了几次, 包括当前这一次
+// 吃子和动兵之后的局面不可能和之前的重复, 所以只需要往回看halfmoveClock个局面
+func (h *GameHistory) RepetitionCount() int {
+	n := len(h.hashes)
+	if n == 0 {
+		return 0
+	}
+
+	current := h.hashes[n-1]
+	count := 1
+	for i := n - 3; i >= 0 && i >= n-1-h.halfmoveClocks[n-1]; i -= 2 {
+		if h.hashes[i] == current {
+			count++
+		}
+	}
+	return count
+}
+
+// 当前的半回合计数
+func (h *GameHistory) HalfmoveClock() int {
+	if len(h.halfmoveClocks) == 0 {
+		return 0
+	}
+	return h.halfmoveClocks[len(h.halfmoveClocks)-1]
+}
+
+// 一方可以申请的和棋, 没有时返回DrawReasonNone
+func (h *GameHistory) ClaimableDraw() DrawReason {
+	if h.RepetitionCount() >= 3 {
+		return DrawReasonThreefoldRepetition
+	}
+	if h.HalfmoveClock() >= 100 {
+		return DrawReasonFiftyMoveRule
+	}
+	return DrawReasonNone
+}
+
+// 不需要申请, 直接判和的情况, 没有时返回DrawReasonNone
+// 75回合规则在最后一步将死对方时不生效, 调用方需要先判断是否将死
+func (h *GameHistory) ForcedDraw() DrawReason {
+	if h.RepetitionCount() >= 5 {
+		return DrawReasonFivefoldRepetition
+	}
+	if h.HalfmoveClock() >= 150 {
+		return DrawReasonSeventyFiveMoveRule
+	}
+	return DrawReasonNone
+}
@@ -0,0 +1,138 @@
+package chess
+
+// 撤销一步棋需要的信息, 由MakeMove返回, 交给UnmakeMove使用
+type Undo struct {
+	Move Move
+	// 被吃掉的棋子, 没有吃子时为nil
+	Captured *ChessPiece
+
+	// 被移动的棋子以及它移动前的状态, 升变时这里是原来的兵
+	piece             *ChessPiece
+	pieceMoved        bool
+	piecePawnMovedTwo bool
+
+	// 被吃掉的棋子原来的下标, 吃过路兵时和To不同
+	capturedIndex int
+
+	// 易位时移动的车以及它的起点和终点的列
+	rook      *ChessPiece
+	rookMoved bool
+	rookFromX int
+	rookToX   int
+
+	// 这一步清除掉PawnMovedTwoLastTime的兵所在的下标
+	clearedEnPassant uint64
+
+	// Atomic中爆炸移除的棋子, 包括吃子的棋子, 它们的X, Y没有被修改
+	exploded []*ChessPiece
+}
+
+// 在棋盘上执行一步并返回撤销需要的信息, 不做合法性检查, 调用方需保证这一步至少是伪合法的
+// 打入不知道是哪一方的棋子, 需要通过Position.MakeMove执行
+func (ct *ChessTable) MakeMove(m Move) Undo {
+	fx, fy := MustPositionToIndex(m.FromX, m.FromY)
+	tx, ty := MustPositionToIndex(m.ToX, m.ToY)
+
+	piece := ct[fy*8+fx]
+	u := Undo{
+		Move:              m,
+		piece:             piece,
+		pieceMoved:        piece.Moved,
+		piecePawnMovedTwo: piece.PawnMovedTwoLastTime,
+		capturedIndex:     ty*8 + tx,
+	}
+	if m.Type == MoveTypeEnPassant {
+		u.capturedIndex = fy*8 + tx
+	}
+
+	// Chess960中王或者车的终点可能是另一个的起点, 所以先把两个都拿起来再放下
+	if m.Type == MoveTypeCastling {
+		u.rookFromX, u.rookToX = ct.castlingRookFiles(m)
+		u.rook = ct.ClearIndex(u.rookFromX, fy)
+		u.rookMoved = u.rook.Moved
+	}
+
+	ct[fy*8+fx] = nil
+	if m.Type != MoveTypeCastling {
+		u.Captured = ct[u.capturedIndex]
+		ct[u.capturedIndex] = nil
+	}
+
+	// 过路兵的机会只保留一个回合
+	u.clearedEnPassant = ct.clearEnPassant()
+
+	if u.rook != nil {
+		u.rook.X, u.rook.Y = indexToPosition(u.rookToX, fy)
+		u.rook.Moved = true
+		ct[fy*8+u.rookToX] = u.rook
+	}
+
+	moved := piece
+	if m.Type == MoveTypePromotion {
+		moved = &ChessPiece{PieceType: m.Promotion, GameSide: piece.GameSide, Promoted: true}
+	}
+	moved.PawnMovedTwoLastTime = piece.PieceType == ChessPieceTypePawn && (ty-fy == 2 || fy-ty == 2)
+	moved.X, moved.Y = m.ToX, m.ToY
+	moved.Moved = true
+	ct[ty*8+tx] = moved
+
+	return u
+}
+
+// 撤销MakeMove执行的一步, 必须按照执行的相反顺序撤销
+func (ct *ChessTable) UnmakeMove(u Undo) {
+	m := u.Move
+	if m.Type == MoveTypeDrop {
+		ct.unmakeDrop(u)
+		return
+	}
+
+	// 先把爆炸移除的棋子放回去, 之后和普通的一步一样撤销
+	for _, p := range u.exploded {
+		ct.SetPosition(p)
+	}
+	fx, fy := MustPositionToIndex(m.FromX, m.FromY)
+	tx, ty := MustPositionToIndex(m.ToX, m.ToY)
+
+	ct[ty*8+tx] = nil
+	if u.rook != nil {
+		ct[fy*8+u.rookToX] = nil
+	}
+
+	u.piece.X, u.piece.Y = m.FromX, m.FromY
+	u.piece.Moved = u.pieceMoved
+	u.piece.PawnMovedTwoLastTime = u.piecePawnMovedTwo
+	ct[fy*8+fx] = u.piece
+
+	if u.rook != nil {
+		u.rook.X, u.rook.Y = indexToPosition(u.rookFromX, fy)
+		u.rook.Moved = u.rookMoved
+		ct[fy*8+u.rookFromX] = u.rook
+	}
+
+	ct.restoreEnPassant(u.clearedEnPassant)
+
+	if u.Captured != nil {
+		ct[u.capturedIndex] = u.Captured
+	}
+}
+
+// 清除所有兵的PawnMovedTwoLastTime, 返回被清除的兵所在下标的位掩码
+func (ct *ChessTable) clearEnPassant() uint64 {
+	var cleared uint64
+	for i := 0; i < 64; i++ {
+		if ct[i] != nil && ct[i].PawnMovedTwoLastTime {
+			ct[i].PawnMovedTwoLastTime = false
+			cleared |= 1 << i
+		}
+	}
+	return cleared
+}
+
+func (ct *ChessTable) restoreEnPassant(cleared uint64) {
+	for i := 0; i < 64; i++ {
+		if cleared&(1<<i) != 0 {
+			ct[i].PawnMovedTwoLastTime = true
+		}
+	}
+}
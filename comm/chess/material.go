@@ -0,0 +1,45 @@
+package chess
+
+// 双方都无法将死对方的死局面: 王对王, 王加一个轻子对王, 以及只剩同色格象的情况
+func (ct *ChessTable) IsInsufficientMaterial() bool {
+	minors := 0
+	knights := 0
+	// 象所在格子的颜色, 0为深色格, 1为浅色格
+	bishopColors := [2]int{}
+
+	for i := 0; i < 64; i++ {
+		p := ct[i]
+		if p == nil {
+			continue
+		}
+		switch p.PieceType {
+		case ChessPieceTypeKing:
+		case ChessPieceTypeKnight:
+			minors++
+			knights++
+		case ChessPieceTypeBishop:
+			minors++
+			bishopColors[(i%8+i/8)%2]++
+		default:
+			// 有兵, 车或后就一定还能将死
+			return false
+		}
+	}
+
+	if minors <= 1 {
+		return true
+	}
+
+	// 没有马, 并且所有的象都在同一种颜色的格子上
+	return knights == 0 && (bishopColors[0] == 0 || bishopColors[1] == 0)
+}
+
+// 棋盘上只剩下双方的王
+func (ct *ChessTable) IsBareKings() bool {
+	for i := 0; i < 64; i++ {
+		if ct[i] != nil && ct[i].PieceType != ChessPieceTypeKing {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,338 @@
+package chess
+
+import "fmt"
+
+type MoveType int
+
+const (
+	// 普通的移动或吃子
+	MoveTypeNormal MoveType = iota
+	// 王车易位, From/To记录的是王的起点和终点
+	MoveTypeCastling
+	// 吃过路兵, To是兵走到的格子, 而不是被吃的兵所在的格子
+	MoveTypeEnPassant
+	// 兵的升变, 升变成什么记录在Promotion中
+	MoveTypePromotion
+	// Crazyhouse中把手里的棋子打入到To, 没有起点, 打入什么记录在Drop中
+	MoveTypeDrop
+)
+
+// 一步棋
+type Move struct {
+	Type MoveType
+	// 比如abcdefgh
+	FromX rune
+	// 比如12345678
+	FromY int
+	ToX   rune
+	ToY   int
+
+	// 只有Type为MoveTypePromotion时有效
+	Promotion ChessPieceType
+	// 只有Type为MoveTypeDrop时有效
+	Drop ChessPieceType
+}
+
+// 坐标记法, 比如e2e4, e7e8q, 打入写成N@e4
+// 易位写成O-O和O-O-O, 因为Chess960中易位可能和王的普通移动有相同的起点和终点
+func (m Move) String() string {
+	switch m.Type {
+	case MoveTypeDrop:
+		return fmt.Sprintf("%c@%c%d", pieceTypeLetter(m.Drop), m.ToX, m.ToY)
+	case MoveTypeCastling:
+		if isKingsideCastling(m) {
+			return "O-O"
+		}
+		return "O-O-O"
+	}
+	s := fmt.Sprintf("%c%d%c%d", m.FromX, m.FromY, m.ToX, m.ToY)
+	if m.Type == MoveTypePromotion {
+		switch m.Promotion {
+		case ChessPieceTypeQueen:
+			s += "q"
+		case ChessPieceTypeRook:
+			s += "r"
+		case ChessPieceTypeBishop:
+			s += "b"
+		case ChessPieceTypeKnight:
+			s += "n"
+		}
+	}
+	return s
+}
+
+var knightOffsets = [8][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+var kingOffsets = [8][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+var rookDirections = [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+var bishopDirections = [4][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+
+// 兵可以升变成的棋子
+var promotionPieceTypes = [4]ChessPieceType{ChessPieceTypeQueen, ChessPieceTypeRook, ChessPieceTypeBishop, ChessPieceTypeKnight}
+
+func onBoard(x int, y int) bool {
+	return x >= 0 && x < 8 && y >= 0 && y < 8
+}
+
+func indexToPosition(x int, y int) (rune, int) {
+	return rune('a' + x), y + 1
+}
+
+func newMove(t MoveType, fromX int, fromY int, toX int, toY int) Move {
+	m := Move{Type: t}
+	m.FromX, m.FromY = indexToPosition(fromX, fromY)
+	m.ToX, m.ToY = indexToPosition(toX, toY)
+	return m
+}
+
+func (s Side) Opponent() Side {
+	if s == SideWhite {
+		return SideBlack
+	}
+	return SideWhite
+}
+
+// 兵前进的方向
+func pawnDirection(side Side) int {
+	if side == SideWhite {
+		return 1
+	}
+	return -1
+}
+
+// 底线, 下标从0开始
+func backRank(side Side) int {
+	if side == SideWhite {
+		return 0
+	}
+	return 7
+}
+
+func (ct *ChessTable) findKing(side Side) (int, int, bool) {
+	for i := 0; i < 64; i++ {
+		p := ct[i]
+		if p != nil && p.PieceType == ChessPieceTypeKing && p.GameSide == side {
+			return i % 8, i / 8, true
+		}
+	}
+	return 0, 0, false
+}
+
+// 生成伪合法的走法, 不考虑走完之后自己的王是否被将军
+func (ct *ChessTable) pseudoLegalMoves(side Side) []Move {
+	moves := make([]Move, 0, 48)
+	for i := 0; i < 64; i++ {
+		p := ct[i]
+		if p == nil || p.GameSide != side {
+			continue
+		}
+		moves = ct.appendPieceMoves(moves, i%8, i/8)
+	}
+	return moves
+}
+
+func (ct *ChessTable) appendPieceMoves(moves []Move, x int, y int) []Move {
+	p := ct.GetIndex(x, y)
+	switch p.PieceType {
+	case ChessPieceTypePawn:
+		moves = ct.appendPawnMoves(moves, x, y)
+	case ChessPieceTypeKnight:
+		moves = ct.appendStepMoves(moves, x, y, knightOffsets[:])
+	case ChessPieceTypeKing:
+		moves = ct.appendStepMoves(moves, x, y, kingOffsets[:])
+		moves = ct.appendCastlingMoves(moves, x, y)
+	case ChessPieceTypeRook:
+		moves = ct.appendSlideMoves(moves, x, y, rookDirections[:])
+	case ChessPieceTypeBishop:
+		moves = ct.appendSlideMoves(moves, x, y, bishopDirections[:])
+	case ChessPieceTypeQueen:
+		moves = ct.appendSlideMoves(moves, x, y, rookDirections[:])
+		moves = ct.appendSlideMoves(moves, x, y, bishopDirections[:])
+	}
+	return moves
+}
+
+func (ct *ChessTable) appendStepMoves(moves []Move, x int, y int, offsets [][2]int) []Move {
+	side := ct.GetIndex(x, y).GameSide
+	for _, o := range offsets {
+		nx, ny := x+o[0], y+o[1]
+		if !onBoard(nx, ny) {
+			continue
+		}
+		if t := ct.GetIndex(nx, ny); t != nil && t.GameSide == side {
+			continue
+		}
+		moves = append(moves, newMove(MoveTypeNormal, x, y, nx, ny))
+	}
+	return moves
+}
+
+func (ct *ChessTable) appendSlideMoves(moves []Move, x int, y int, directions [][2]int) []Move {
+	side := ct.GetIndex(x, y).GameSide
+	for _, d := range directions {
+		for nx, ny := x+d[0], y+d[1]; onBoard(nx, ny); nx, ny = nx+d[0], ny+d[1] {
+			t := ct.GetIndex(nx, ny)
+			if t != nil && t.GameSide == side {
+				break
+			}
+			moves = append(moves, newMove(MoveTypeNormal, x, y, nx, ny))
+			if t != nil {
+				break
+			}
+		}
+	}
+	return moves
+}
+
+// 兵走到底线时展开成四种升变
+func appendPawnMove(moves []Move, x int, y int, nx int, ny int) []Move {
+	if ny != 0 && ny != 7 {
+		return append(moves, newMove(MoveTypeNormal, x, y, nx, ny))
+	}
+	for _, t := range promotionPieceTypes {
+		m := newMove(MoveTypePromotion, x, y, nx, ny)
+		m.Promotion = t
+		moves = append(moves, m)
+	}
+	return moves
+}
+
+func (ct *ChessTable) appendPawnMoves(moves []Move, x int, y int) []Move {
+	side := ct.GetIndex(x, y).GameSide
+	dir := pawnDirection(side)
+
+	// 前进一格, 在初始位置时可以前进两格
+	if onBoard(x, y+dir) && ct.GetIndex(x, y+dir) == nil {
+		moves = appendPawnMove(moves, x, y, x, y+dir)
+		startRank := 1
+		if side == SideBlack {
+			startRank = 6
+		}
+		if y == startRank && ct.GetIndex(x, y+2*dir) == nil {
+			moves = append(moves, newMove(MoveTypeNormal, x, y, x, y+2*dir))
+		}
+	}
+
+	for _, dx := range [2]int{-1, 1} {
+		nx, ny := x+dx, y+dir
+		if !onBoard(nx, ny) {
+			continue
+		}
+		if t := ct.GetIndex(nx, ny); t != nil {
+			if t.GameSide != side {
+				moves = appendPawnMove(moves, x, y, nx, ny)
+			}
+			continue
+		}
+
+		// 吃过路兵, 旁边是对方上一步刚走了两格的兵
+		t := ct.GetIndex(nx, y)
+		if t != nil && t.GameSide != side && t.PieceType == ChessPieceTypePawn && t.PawnMovedTwoLastTime &&
+			y == backRank(side.Opponent())+3*pawnDirection(side.Opponent()) {
+			moves = append(moves, newMove(MoveTypeEnPassant, x, y, nx, ny))
+		}
+	}
+	return moves
+}
+
+// 王和车都没有移动过, 两者之间以及经过的格子没有别的棋子, 王经过的格子都不被攻击
+// 标准国际象棋只是Chess960的一种特殊情况, 所以这里不区分两者
+func (ct *ChessTable) appendCastlingMoves(moves []Move, x int, y int) []Move {
+	king := ct.GetIndex(x, y)
+	side := king.GameSide
+	if king.Moved || y != backRank(side) {
+		return moves
+	}
+
+	for _, kingside := range [2]bool{true, false} {
+		rookX, ok := ct.castlingRookX(side, x, kingside)
+		if !ok {
+			continue
+		}
+		kingToX, rookToX := castlingTargetFiles(kingside)
+		if !ct.castlingPathClear(y, x, kingToX, rookX, rookToX) {
+			continue
+		}
+
+		// 王的起点, 终点以及中间的格子都不能被攻击
+		step := 1
+		if kingToX < x {
+			step = -1
+		}
+		safe := true
+		for kx := x; ; kx += step {
+			if ct.isSquareAttacked(kx, y, side.Opponent()) {
+				safe = false
+				break
+			}
+			if kx == kingToX {
+				break
+			}
+		}
+		if safe {
+			moves = append(moves, newMove(MoveTypeCastling, x, y, kingToX, y))
+		}
+	}
+	return moves
+}
+
+// 直接在棋盘上执行一步, 不做合法性检查, 调用方需保证这一步至少是伪合法的
+func (ct *ChessTable) ApplyMove(m Move) {
+	ct.MakeMove(m)
+}
+
+// 走完这一步后side方的王是否安全
+func (ct *ChessTable) leavesKingSafe(m Move, side Side) bool {
+	u := ct.MakeMove(m)
+	defer ct.UnmakeMove(u)
+	kx, ky, ok := ct.findKing(side)
+	if !ok {
+		return true
+	}
+	return !ct.isSquareAttacked(kx, ky, side.Opponent())
+}
+
+// 返回side方所有合法的走法, 包括王车易位, 吃过路兵和升变
+func (ct *ChessTable) LegalMoves(side Side) []Move {
+	pseudo := ct.pseudoLegalMoves(side)
+	moves := pseudo[:0]
+	for _, m := range pseudo {
+		if ct.leavesKingSafe(m, side) {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// 返回某个格子上棋子的所有合法走法, 格子为空时返回nil
+func (ct *ChessTable) LegalMovesFrom(X rune, Y int) []Move {
+	x, y := MustPositionToIndex(X, Y)
+	p := ct.GetIndex(x, y)
+	if p == nil {
+		return nil
+	}
+	pseudo := ct.appendPieceMoves(nil, x, y)
+	moves := pseudo[:0]
+	for _, m := range pseudo {
+		if ct.leavesKingSafe(m, p.GameSide) {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// 判断一步棋是否合法, 只比较起点, 终点, 是否易位和升变的棋子
+func (ct *ChessTable) IsLegalMove(m Move) bool {
+	p := ct.GetPosition(m.FromX, m.FromY)
+	if p == nil {
+		return false
+	}
+	for _, lm := range ct.LegalMovesFrom(m.FromX, m.FromY) {
+		if lm.ToX != m.ToX || lm.ToY != m.ToY || (lm.Type == MoveTypeCastling) != (m.Type == MoveTypeCastling) {
+			continue
+		}
+		if lm.Type != MoveTypePromotion || lm.Promotion == m.Promotion {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package chess
+
+// 从当前局面开始, 数depth层之后的叶子节点个数, 用来验证走法生成
+// Crazyhouse和Atomic的走法不同, 需要在局面上执行
+func (p *Position) Perft(depth int) uint64 {
+	if p.Variant == VariantCrazyhouse || p.Variant == VariantAtomic {
+		return positionPerft(p, depth)
+	}
+	return perft(p.Table, p.SideToMove, depth)
+}
+
+func positionPerft(p *Position, depth int) uint64 {
+	if depth == 0 {
+		return 1
+	}
+
+	moves := p.LegalMoves()
+	if depth == 1 {
+		return uint64(len(moves))
+	}
+
+	var nodes uint64
+	for _, m := range moves {
+		u := p.MakeMove(m)
+		nodes += positionPerft(p, depth-1)
+		p.UnmakeMove(u)
+	}
+	return nodes
+}
+
+func perft(ct *ChessTable, side Side, depth int) uint64 {
+	if depth == 0 {
+		return 1
+	}
+
+	moves := ct.LegalMoves(side)
+	if depth == 1 {
+		return uint64(len(moves))
+	}
+
+	var nodes uint64
+	for _, m := range moves {
+		u := ct.MakeMove(m)
+		nodes += perft(ct, side.Opponent(), depth-1)
+		ct.UnmakeMove(u)
+	}
+	return nodes
+}
+
+// 每一步棋下面的叶子节点个数
+type PerftDivideEntry struct {
+	Move  Move
+	Nodes uint64
+}
+
+// 按第一步拆分的perft, 和其他引擎的输出逐步对比可以快速定位出错的走法
+func (p *Position) PerftDivide(depth int) []PerftDivideEntry {
+	if depth < 1 {
+		return nil
+	}
+
+	moves := p.LegalMoves()
+	entries := make([]PerftDivideEntry, 0, len(moves))
+	for _, m := range moves {
+		u := p.MakeMove(m)
+		entries = append(entries, PerftDivideEntry{Move: m, Nodes: p.Perft(depth - 1)})
+		p.UnmakeMove(u)
+	}
+	return entries
+}
+
+// 一个标准的perft测试局面, Nodes[i]是深度为i+1时的节点数
+type PerftCase struct {
+	Name  string
+	FEN   string
+	Nodes []uint64
+}
+
+// 常用的perft测试局面, 覆盖了易位, 过路兵, 升变和牵制
+var PerftSuite = []PerftCase{
+	{
+		Name:  "start position",
+		FEN:   StartFEN,
+		Nodes: []uint64{20, 400, 8902, 197281, 4865609},
+	},
+	{
+		Name:  "kiwipete",
+		FEN:   "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		Nodes: []uint64{48, 2039, 97862, 4085603},
+	},
+	{
+		Name:  "position 3",
+		FEN:   "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		Nodes: []uint64{14, 191, 2812, 43238, 674624},
+	},
+	{
+		Name:  "position 4",
+		FEN:   "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		Nodes: []uint64{6, 264, 9467, 422333},
+	},
+	{
+		Name:  "position 4 mirrored",
+		FEN:   "r2q1rk1/pP1p2pp/Q4n2/bbp1p3/Np6/1B3NBn/pPPP1PPP/R3K2R b KQ - 0 1",
+		Nodes: []uint64{6, 264, 9467, 422333},
+	},
+	{
+		Name:  "position 5",
+		FEN:   "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		Nodes: []uint64{44, 1486, 62379, 2103487},
+	},
+	{
+		Name:  "position 6",
+		FEN:   "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+		Nodes: []uint64{46, 2079, 89890, 3894594},
+	},
+	{
+		Name:  "chess960 1",
+		FEN:   "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+		Nodes: []uint64{21, 528, 12189, 326672},
+	},
+	{
+		Name:  "chess960 2",
+		FEN:   "2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9",
+		Nodes: []uint64{21, 807, 18002, 667366},
+	},
+	{
+		Name:  "chess960 3",
+		FEN:   "qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w hf - 0 9",
+		Nodes: []uint64{22, 593, 13440, 382958},
+	},
+	{
+		Name:  "chess960 4",
+		FEN:   "1nbbnrkr/p1p1ppp1/3p4/1p3P1p/3Pq2P/8/PPP1P1P1/QNBBNRKR w HFhf - 0 9",
+		Nodes: []uint64{28, 1120, 31058, 1171749},
+	},
+}
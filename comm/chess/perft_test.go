@@ -0,0 +1,100 @@
+package chess
+
+import (
+	"sort"
+	"testing"
+)
+
+// 完整测试时最多跑到的深度, -short时更浅
+func perftTestDepth() int {
+	if testing.Short() {
+		return 3
+	}
+	return 4
+}
+
+func TestPerftSuite(t *testing.T) {
+	for _, c := range PerftSuite {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			p, err := ParsePositionFEN(c.FEN)
+			if err != nil {
+				t.Fatalf("parse fen: %v", err)
+			}
+			b := NewBitboardPosition(p.Table)
+
+			for i, want := range c.Nodes {
+				depth := i + 1
+				if depth > perftTestDepth() {
+					break
+				}
+				if got := p.Perft(depth); got != want {
+					t.Errorf("table depth %d: got %d, want %d", depth, got, want)
+				}
+				if got := b.Perft(p.SideToMove, depth); got != want {
+					t.Errorf("bitboard depth %d: got %d, want %d", depth, got, want)
+				}
+			}
+		})
+	}
+}
+
+func sortedMoveStrings(moves []Move) []string {
+	s := make([]string, 0, len(moves))
+	for _, m := range moves {
+		s = append(s, m.String())
+	}
+	sort.Strings(s)
+	return s
+}
+
+// 在每个节点上比较两种生成器的着法列表, 节点数一致时也能发现互相抵消的错误
+func compareGenerators(t *testing.T, ct *ChessTable, side Side, depth int) {
+	t.Helper()
+	table := sortedMoveStrings(ct.LegalMoves(side))
+	bitboard := sortedMoveStrings(NewBitboardPosition(ct).LegalMoves(side))
+	if len(table) != len(bitboard) {
+		t.Fatalf("%s: table has %d moves, bitboard has %d", ct.FEN(side, 0, 1), len(table), len(bitboard))
+	}
+	for i := range table {
+		if table[i] != bitboard[i] {
+			t.Fatalf("%s: table has %s, bitboard has %s", ct.FEN(side, 0, 1), table[i], bitboard[i])
+		}
+	}
+	if depth <= 1 {
+		return
+	}
+	for _, m := range ct.LegalMoves(side) {
+		u := ct.MakeMove(m)
+		compareGenerators(t, ct, side.Opponent(), depth-1)
+		ct.UnmakeMove(u)
+	}
+}
+
+func TestPerftGeneratorsAgree(t *testing.T) {
+	for _, c := range PerftSuite {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			p, err := ParsePositionFEN(c.FEN)
+			if err != nil {
+				t.Fatalf("parse fen: %v", err)
+			}
+			compareGenerators(t, p.Table, p.SideToMove, perftTestDepth()-1)
+		})
+	}
+}
+
+// go test -bench Perft -run '^$' ./comm/chess 比较两种生成器的速度
+func BenchmarkPerftTable(b *testing.B) {
+	p := NewPosition()
+	for i := 0; i < b.N; i++ {
+		p.Perft(4)
+	}
+}
+
+func BenchmarkPerftBitboard(b *testing.B) {
+	p := NewPosition()
+	for i := 0; i < b.N; i++ {
+		NewBitboardPosition(p.Table).Perft(p.SideToMove, 4)
+	}
+}
@@ -0,0 +1,187 @@
+package chess
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	PGNResultWhiteWins = "1-0"
+	PGNResultBlackWins = "0-1"
+	PGNResultDraw      = "1/2-1/2"
+	// 对局还没结束或者结果未知
+	PGNResultUnknown = "*"
+)
+
+// 根据胜利的一方得到PGN的结果, SideBoth表示平局
+func PGNResultFromWinner(winner Side) string {
+	switch winner {
+	case SideWhite:
+		return PGNResultWhiteWins
+	case SideBlack:
+		return PGNResultBlackWins
+	case SideBoth:
+		return PGNResultDraw
+	default:
+		return PGNResultUnknown
+	}
+}
+
+type PGNTag struct {
+	Name  string
+	Value string
+}
+
+// 一盘要导出成PGN的对局
+type PGNGame struct {
+	// 按顺序输出, 前七个是Seven Tag Roster
+	Tags []PGNTag
+	// 标准代数记法的着法
+	Moves []string
+	// 写在着法最后, 结果之前的注释, 比如认输
+	FinalComment string
+}
+
+// 创建一盘对局, Seven Tag Roster都填上未知的默认值
+func NewPGNGame() *PGNGame {
+	return &PGNGame{
+		Tags: []PGNTag{
+			{Name: "Event", Value: "?"},
+			{Name: "Site", Value: "?"},
+			{Name: "Date", Value: "????.??.??"},
+			{Name: "Round", Value: "-"},
+			{Name: "White", Value: "?"},
+			{Name: "Black", Value: "?"},
+			{Name: "Result", Value: PGNResultUnknown},
+		},
+	}
+}
+
+// 修改已有的标签, 不存在时追加到最后
+func (g *PGNGame) SetTag(name string, value string) {
+	for i := range g.Tags {
+		if g.Tags[i].Name == name {
+			g.Tags[i].Value = value
+			return
+		}
+	}
+	g.Tags = append(g.Tags, PGNTag{Name: name, Value: value})
+}
+
+// 标签的值, 不存在时返回空字符串
+func (g *PGNGame) Tag(name string) string {
+	for _, t := range g.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+func escapePGNString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+// 导出成PGN文本, 着法部分每行不超过80个字符
+func (g *PGNGame) String() string {
+	var sb strings.Builder
+	for _, t := range g.Tags {
+		fmt.Fprintf(&sb, "[%s \"%s\"]\n", t.Name, escapePGNString(t.Value))
+	}
+	sb.WriteByte('\n')
+
+	// 有FEN标签时从FEN中的回合数和轮到的一方开始编号
+	side, fullmove := SideWhite, 1
+	if fen := g.Tag("FEN"); fen != "" {
+		if _, s, _, f, err := ParseFEN(fen); err == nil {
+			side, fullmove = s, f
+		}
+	}
+
+	tokens := make([]string, 0, len(g.Moves)*3/2+2)
+	for i, m := range g.Moves {
+		if side == SideWhite {
+			tokens = append(tokens, fmt.Sprintf("%d.", fullmove))
+		} else if i == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d...", fullmove))
+		}
+		tokens = append(tokens, m)
+		if side == SideBlack {
+			fullmove++
+		}
+		side = side.Opponent()
+	}
+	if g.FinalComment != "" {
+		tokens = append(tokens, "{"+strings.ReplaceAll(g.FinalComment, "}", "")+"}")
+	}
+	result := g.Tag("Result")
+	if result == "" {
+		result = PGNResultUnknown
+	}
+	tokens = append(tokens, result)
+
+	lineLen := 0
+	for i, t := range tokens {
+		if i > 0 {
+			if lineLen+1+len(t) > 80 {
+				sb.WriteByte('\n')
+				lineLen = 0
+			} else {
+				sb.WriteByte(' ')
+				lineLen++
+			}
+		}
+		sb.WriteString(t)
+		lineLen += len(t)
+	}
+	sb.WriteString("\n\n")
+
+	return sb.String()
+}
+
+func (g *PGNGame) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, g.String())
+	return int64(n), err
+}
+
+// 两个棋盘上每个格子的棋子类型和所属的一方是否都相同, 不比较棋子的状态
+func (ct *ChessTable) SamePlacement(other *ChessTable) bool {
+	for i := 0; i < 64; i++ {
+		a, b := ct[i], other[i]
+		if (a == nil) != (b == nil) {
+			return false
+		}
+		if a != nil && (a.PieceType != b.PieceType || a.GameSide != b.GameSide) {
+			return false
+		}
+	}
+	return true
+}
+
+// 找出side方从before走到after的那一步, 服务端只发送棋盘时用来还原着法
+func FindMove(before *ChessTable, after *ChessTable, side Side) (Move, bool) {
+	for _, m := range before.LegalMoves(side) {
+		u := before.MakeMove(m)
+		same := before.SamePlacement(after)
+		before.UnmakeMove(u)
+		if same {
+			return m, true
+		}
+	}
+	return Move{}, false
+}
+
+// 和FindMove相同, 但是在局面上查找, 所以Crazyhouse的打入也能找到
+func (p *Position) FindMove(after *ChessTable) (Move, bool) {
+	for _, m := range p.LegalMoves() {
+		u := p.MakeMove(m)
+		same := p.Table.SamePlacement(after)
+		p.UnmakeMove(u)
+		if same {
+			return m, true
+		}
+	}
+	return Move{}, false
+}
@@ -0,0 +1,442 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var ErrInvalidPGN = errors.New("invalid pgn")
+
+// 棋谱树上的一个节点, 表示一步棋以及走完之后的局面
+// 根节点不对应任何着法, 只保存开始局面和开头的注释
+type PGNNode struct {
+	Parent *PGNNode
+	// Children[0]是主线, 其余是变着
+	Children []*PGNNode
+
+	Move Move
+	SAN  string
+	// 走完这一步之后的局面
+	Position *Position
+
+	// 着法前面的注释, 只有变着的第一步会有
+	PreComment string
+	// 着法后面的注释
+	Comment string
+	// 数字注释, 比如$1, !和?这样的后缀也会被转换成对应的数字
+	NAGs []int
+}
+
+// 主线的下一步, 没有时返回nil
+func (n *PGNNode) Next() *PGNNode {
+	if len(n.Children) == 0 {
+		return nil
+	}
+	return n.Children[0]
+}
+
+// 从PGN读出来的一盘对局
+type PGNGameTree struct {
+	Tags []PGNTag
+	Root *PGNNode
+	// 着法最后的结果, 比如1-0
+	Result string
+}
+
+func (g *PGNGameTree) Tag(name string) string {
+	for _, t := range g.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// 主线上所有的节点, 不包括根节点
+func (g *PGNGameTree) MainLine() []*PGNNode {
+	var nodes []*PGNNode
+	for n := g.Root.Next(); n != nil; n = n.Next() {
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// 转换成只包含主线的PGNGame, 用于重新导出
+func (g *PGNGameTree) ToPGNGame() *PGNGame {
+	pg := &PGNGame{Tags: append([]PGNTag(nil), g.Tags...)}
+	for _, n := range g.MainLine() {
+		pg.Moves = append(pg.Moves, n.SAN)
+	}
+	return pg
+}
+
+type pgnTokenType int
+
+const (
+	pgnTokenSymbol pgnTokenType = iota
+	pgnTokenString
+	pgnTokenComment
+	pgnTokenNAG
+	pgnTokenOpenBracket
+	pgnTokenCloseBracket
+	pgnTokenOpenParen
+	pgnTokenCloseParen
+	pgnTokenEOF
+)
+
+type pgnToken struct {
+	Type  pgnTokenType
+	Value string
+	Line  int
+}
+
+type pgnLexer struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func (l *pgnLexer) next() (pgnToken, error) {
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		if r == '\n' {
+			l.line++
+		}
+		// %开头的行是转义行, ;开头的是行注释, 都直接跳过
+		if (r == '%' && (l.pos == 0 || l.src[l.pos-1] == '\n')) || r == ';' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		if !unicode.IsSpace(r) {
+			break
+		}
+		l.pos++
+	}
+
+	if l.pos >= len(l.src) {
+		return pgnToken{Type: pgnTokenEOF, Line: l.line}, nil
+	}
+
+	line := l.line
+	r := l.src[l.pos]
+	switch r {
+	case '[':
+		l.pos++
+		return pgnToken{Type: pgnTokenOpenBracket, Line: line}, nil
+	case ']':
+		l.pos++
+		return pgnToken{Type: pgnTokenCloseBracket, Line: line}, nil
+	case '(':
+		l.pos++
+		return pgnToken{Type: pgnTokenOpenParen, Line: line}, nil
+	case ')':
+		l.pos++
+		return pgnToken{Type: pgnTokenCloseParen, Line: line}, nil
+	case '{':
+		end := l.pos + 1
+		for end < len(l.src) && l.src[end] != '}' {
+			if l.src[end] == '\n' {
+				l.line++
+			}
+			end++
+		}
+		if end >= len(l.src) {
+			return pgnToken{}, fmt.Errorf("%w: line %d: unterminated comment", ErrInvalidPGN, line)
+		}
+		s := string(l.src[l.pos+1 : end])
+		l.pos = end + 1
+		return pgnToken{Type: pgnTokenComment, Value: strings.TrimSpace(s), Line: line}, nil
+	case '"':
+		var sb strings.Builder
+		l.pos++
+		for l.pos < len(l.src) && l.src[l.pos] != '"' {
+			if l.src[l.pos] == '\\' && l.pos+1 < len(l.src) {
+				l.pos++
+			}
+			sb.WriteRune(l.src[l.pos])
+			l.pos++
+		}
+		if l.pos >= len(l.src) {
+			return pgnToken{}, fmt.Errorf("%w: line %d: unterminated string", ErrInvalidPGN, line)
+		}
+		l.pos++
+		return pgnToken{Type: pgnTokenString, Value: sb.String(), Line: line}, nil
+	case '$':
+		start := l.pos + 1
+		l.pos++
+		for l.pos < len(l.src) && unicode.IsDigit(l.src[l.pos]) {
+			l.pos++
+		}
+		return pgnToken{Type: pgnTokenNAG, Value: string(l.src[start:l.pos]), Line: line}, nil
+	}
+
+	start := l.pos
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		if unicode.IsSpace(r) || strings.ContainsRune("[](){}\";$", r) {
+			break
+		}
+		l.pos++
+	}
+	return pgnToken{Type: pgnTokenSymbol, Value: string(l.src[start:l.pos]), Line: line}, nil
+}
+
+// 着法后缀对应的数字注释
+var pgnSuffixNAGs = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+func isPGNResult(s string) bool {
+	return s == PGNResultWhiteWins || s == PGNResultBlackWins || s == PGNResultDraw || s == PGNResultUnknown
+}
+
+type pgnParser struct {
+	lexer  *pgnLexer
+	peeked *pgnToken
+}
+
+func (p *pgnParser) peek() (pgnToken, error) {
+	if p.peeked == nil {
+		t, err := p.lexer.next()
+		if err != nil {
+			return t, err
+		}
+		p.peeked = &t
+	}
+	return *p.peeked, nil
+}
+
+func (p *pgnParser) next() (pgnToken, error) {
+	t, err := p.peek()
+	p.peeked = nil
+	return t, err
+}
+
+// 读取PGN文件中所有的对局, 每一步都会按照规则检查
+func ReadPGN(r io.Reader) ([]*PGNGameTree, error) {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePGN(string(bs))
+}
+
+func ParsePGN(s string) ([]*PGNGameTree, error) {
+	p := &pgnParser{lexer: &pgnLexer{src: []rune(s), line: 1}}
+	var games []*PGNGameTree
+	for {
+		t, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type == pgnTokenEOF {
+			return games, nil
+		}
+		g, err := p.parseGame()
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+}
+
+func (p *pgnParser) parseGame() (*PGNGameTree, error) {
+	g := &PGNGameTree{Result: PGNResultUnknown}
+
+	for {
+		t, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type != pgnTokenOpenBracket {
+			break
+		}
+		p.next()
+		name, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		end, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if name.Type != pgnTokenSymbol || value.Type != pgnTokenString || end.Type != pgnTokenCloseBracket {
+			return nil, fmt.Errorf("%w: line %d: bad tag pair", ErrInvalidPGN, t.Line)
+		}
+		g.Tags = append(g.Tags, PGNTag{Name: name.Value, Value: value.Value})
+	}
+
+	variant, ok := ParseVariant(g.Tag("Variant"))
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported variant %q", ErrInvalidPGN, g.Tag("Variant"))
+	}
+	start := NewPosition()
+	if fen := g.Tag("FEN"); fen != "" {
+		pos, err := ParsePositionFEN(fen)
+		if err != nil {
+			return nil, err
+		}
+		start = pos
+	}
+	// 打入, 爆炸和SAN的写法都由开始局面的变体决定, 没有标签时保留FEN推断出的变体
+	if variant != VariantStandard {
+		start.Variant = variant
+	}
+	g.Root = &PGNNode{Position: start}
+
+	result, err := p.parseMoves(g.Root, false)
+	if err != nil {
+		return nil, err
+	}
+	if result != "" {
+		g.Result = result
+	}
+	return g, nil
+}
+
+// 解析parent之后的着法, 直到遇到结果, 下一盘对局或者变着结束
+// 返回遇到的结果, 变着里面没有结果
+func (p *pgnParser) parseMoves(parent *PGNNode, inVariation bool) (string, error) {
+	// last是最后一步, 变着从last的父节点分出去
+	last := parent
+	pendingComment := ""
+
+	for {
+		t, err := p.peek()
+		if err != nil {
+			return "", err
+		}
+
+		switch t.Type {
+		case pgnTokenEOF, pgnTokenOpenBracket:
+			if inVariation {
+				return "", fmt.Errorf("%w: line %d: unterminated variation", ErrInvalidPGN, t.Line)
+			}
+			return "", nil
+		case pgnTokenCloseParen:
+			if !inVariation {
+				return "", fmt.Errorf("%w: line %d: unexpected )", ErrInvalidPGN, t.Line)
+			}
+			p.next()
+			return "", nil
+		case pgnTokenOpenParen:
+			p.next()
+			if last == parent {
+				return "", fmt.Errorf("%w: line %d: variation before any move", ErrInvalidPGN, t.Line)
+			}
+			if _, err := p.parseMoves(last.Parent, true); err != nil {
+				return "", err
+			}
+		case pgnTokenComment:
+			p.next()
+			if last == parent && parent.Parent == nil && len(parent.Children) == 0 {
+				// 第一步之前的注释算作整盘棋的注释
+				parent.Comment = joinPGNComment(parent.Comment, t.Value)
+			} else if last == parent {
+				pendingComment = joinPGNComment(pendingComment, t.Value)
+			} else {
+				last.Comment = joinPGNComment(last.Comment, t.Value)
+			}
+		case pgnTokenNAG:
+			p.next()
+			n, err := strconv.Atoi(t.Value)
+			if err != nil || last == parent {
+				return "", fmt.Errorf("%w: line %d: bad nag $%s", ErrInvalidPGN, t.Line, t.Value)
+			}
+			last.NAGs = append(last.NAGs, n)
+		case pgnTokenSymbol:
+			p.next()
+			if isPGNResult(t.Value) {
+				if inVariation {
+					continue
+				}
+				return t.Value, nil
+			}
+
+			// 有的棋谱在吃过路兵后面单独写上e.p.
+			if t.Value == "e.p." && last != parent && last.Move.Type == MoveTypeEnPassant {
+				continue
+			}
+
+			san := stripPGNMoveNumber(t.Value)
+			if san == "" {
+				continue
+			}
+			node, err := addPGNMove(last, san, t.Line)
+			if err != nil {
+				return "", err
+			}
+			node.PreComment = pendingComment
+			pendingComment = ""
+			last = node
+		default:
+			return "", fmt.Errorf("%w: line %d: unexpected token", ErrInvalidPGN, t.Line)
+		}
+	}
+}
+
+func joinPGNComment(a string, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + " " + b
+}
+
+// 去掉着法前面的回合数, 比如12.e4和12...e4, 只有回合数时返回空字符串
+func stripPGNMoveNumber(s string) string {
+	if strings.Trim(s, ".") == "" {
+		return ""
+	}
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 || i == len(s) || s[i] != '.' {
+		if i == len(s) {
+			return ""
+		}
+		return s
+	}
+	for i < len(s) && s[i] == '.' {
+		i++
+	}
+	return s[i:]
+}
+
+// 在parent后面添加一步, 检查是否合法
+func addPGNMove(parent *PGNNode, san string, line int) (*PGNNode, error) {
+	var nags []int
+	trimmed := strings.TrimRight(san, "!?")
+	if suffix := san[len(trimmed):]; suffix != "" {
+		n, ok := pgnSuffixNAGs[suffix]
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d: bad move suffix %q", ErrInvalidPGN, line, san)
+		}
+		nags = append(nags, n)
+	}
+
+	pos := parent.Position
+	m, err := pos.ParseSAN(trimmed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidPGN, line, err)
+	}
+
+	node := &PGNNode{
+		Parent: parent,
+		Move:   m,
+		SAN:    pos.MoveToSAN(m),
+		NAGs:   nags,
+	}
+	node.Position = pos.Copy()
+	node.Position.MakeMove(m)
+	parent.Children = append(parent.Children, node)
+	return node, nil
+}
@@ -0,0 +1,269 @@
+package chess
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func pgnSANs(nodes []*PGNNode) []string {
+	sans := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		sans = append(sans, n.SAN)
+	}
+	return sans
+}
+
+func TestParsePGN(t *testing.T) {
+	cases := []struct {
+		name string
+		pgn  string
+		// 每盘对局的主线
+		mainLines [][]string
+		check     func(t *testing.T, games []*PGNGameTree)
+	}{
+		{
+			name:      "comments",
+			pgn:       "{before} 1. e4 {king pawn} e5 ({alt} 1... c5 {sicilian}) *",
+			mainLines: [][]string{{"e4", "e5"}},
+			check: func(t *testing.T, games []*PGNGameTree) {
+				root := games[0].Root
+				e4 := root.Next()
+				c5 := e4.Children[1]
+				if root.Comment != "before" || e4.Comment != "king pawn" || c5.PreComment != "alt" || c5.Comment != "sicilian" {
+					t.Errorf("comments: root %q, e4 %q, c5 pre %q, c5 %q", root.Comment, e4.Comment, c5.PreComment, c5.Comment)
+				}
+			},
+		},
+		{
+			name:      "nags and suffixes",
+			pgn:       "1. e4! e5?! 2. Nf3 $14 Nc6!? 3. Bb5?? $4 *",
+			mainLines: [][]string{{"e4", "e5", "Nf3", "Nc6", "Bb5"}},
+			check: func(t *testing.T, games []*PGNGameTree) {
+				want := [][]int{{1}, {6}, {14}, {5}, {4, 4}}
+				for i, n := range games[0].MainLine() {
+					if !reflect.DeepEqual(n.NAGs, want[i]) {
+						t.Errorf("%s: nags %v, want %v", n.SAN, n.NAGs, want[i])
+					}
+				}
+			},
+		},
+		{
+			name:      "nested variations",
+			pgn:       "1. e4 e5 (1... c5 2. Nf3 (2. c3 d5) d6) (1... e6) 2. Nf3 *",
+			mainLines: [][]string{{"e4", "e5", "Nf3"}},
+			check: func(t *testing.T, games []*PGNGameTree) {
+				e4 := games[0].Root.Next()
+				if got := pgnSANs(e4.Children); !reflect.DeepEqual(got, []string{"e5", "c5", "e6"}) {
+					t.Fatalf("replies to e4: %v", got)
+				}
+				c5 := e4.Children[1]
+				if got := pgnSANs(c5.Children); !reflect.DeepEqual(got, []string{"Nf3", "c3"}) {
+					t.Fatalf("replies to c5: %v", got)
+				}
+				if got := pgnSANs(c5.Children[1].Children); !reflect.DeepEqual(got, []string{"d5"}) {
+					t.Errorf("replies to c3: %v", got)
+				}
+				if got := pgnSANs(c5.Children[0].Children); !reflect.DeepEqual(got, []string{"d6"}) {
+					t.Errorf("replies to Nf3: %v", got)
+				}
+			},
+		},
+		{
+			name:      "escapes and line comments",
+			pgn:       "% exported by some tool\n[Event \"a;b\"]\n\n1. e4 ; rest of the line 2. d4\ne5 *",
+			mainLines: [][]string{{"e4", "e5"}},
+			check: func(t *testing.T, games []*PGNGameTree) {
+				if got := games[0].Tag("Event"); got != "a;b" {
+					t.Errorf("event %q", got)
+				}
+			},
+		},
+		{
+			name: "multiple games",
+			pgn:  "[Result \"1-0\"]\n\n1. e4 e5 1-0\n\n[Result \"0-1\"]\n\n1. d4 d5 2. c4 0-1\n",
+			mainLines: [][]string{
+				{"e4", "e5"},
+				{"d4", "d5", "c4"},
+			},
+			check: func(t *testing.T, games []*PGNGameTree) {
+				if games[0].Result != PGNResultWhiteWins || games[1].Result != PGNResultBlackWins {
+					t.Errorf("results %q, %q", games[0].Result, games[1].Result)
+				}
+			},
+		},
+		{
+			name:      "promotion without equals sign",
+			pgn:       "[FEN \"4k3/P7/8/8/8/8/8/4K3 w - - 0 1\"]\n\n1. a8Q+ Kd7 *",
+			mainLines: [][]string{{"a8=Q+", "Kd7"}},
+		},
+		{
+			name:      "en passant marker",
+			pgn:       "[FEN \"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1\"]\n\n1. exd6 e.p. Kd7 *",
+			mainLines: [][]string{{"exd6", "Kd7"}},
+		},
+		{
+			name:      "en passant marker in the move",
+			pgn:       "[FEN \"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1\"]\n\n1. exd6e.p. Kd7 *",
+			mainLines: [][]string{{"exd6", "Kd7"}},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			games, err := ParsePGN(c.pgn)
+			if err != nil {
+				t.Fatalf("parse pgn: %v", err)
+			}
+			if len(games) != len(c.mainLines) {
+				t.Fatalf("got %d games, want %d", len(games), len(c.mainLines))
+			}
+			for i, g := range games {
+				if got := pgnSANs(g.MainLine()); !reflect.DeepEqual(got, c.mainLines[i]) {
+					t.Errorf("game %d: main line %v, want %v", i+1, got, c.mainLines[i])
+				}
+			}
+			if c.check != nil {
+				c.check(t, games)
+			}
+		})
+	}
+}
+
+func TestParsePGNErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		pgn  string
+	}{
+		{"unterminated comment", "1. e4 {never closed"},
+		{"unterminated variation", "1. e4 e5 (1... c5 2. Nf3"},
+		{"unexpected close paren", "1. e4 e5) *"},
+		{"variation before any move", "(1. e4) *"},
+		{"unterminated string", "[Event \"x]\n\n1. e4 *"},
+		{"bad tag pair", "[Event]\n\n1. e4 *"},
+		{"illegal move", "1. e4 e5 2. Ke3 *"},
+		{"en passant marker after a normal move", "1. e4 e.p. *"},
+		{"bad suffix", "1. e4!!? *"},
+		{"unsupported variant", "[Variant \"Bughouse\"]\n\n1. e4 *"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := ParsePGN(c.pgn); !errors.Is(err, ErrInvalidPGN) {
+				t.Errorf("got error %v, want %v", err, ErrInvalidPGN)
+			}
+		})
+	}
+}
+
+// 从v的标准开局随机走最多plies步, 对局结束时提前停下
+func randomGame(v Variant, seed int64, plies int) *Game {
+	start, _ := ParsePositionFEN(v.startFEN())
+	g := NewVariantGame(start, v)
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; i < plies && !g.IsOver(); i++ {
+		moves := g.Position.LegalMoves()
+		if len(moves) == 0 {
+			break
+		}
+		g.MakeMove(moves[r.Intn(len(moves))])
+	}
+	return g
+}
+
+// 导出成PGN再读回来, 主线上的着法和局面都要和原来的对局一致
+func checkPGNRoundTrip(t *testing.T, g *Game) {
+	t.Helper()
+	text := g.PGN().String()
+	trees, err := ParsePGN(text)
+	if err != nil {
+		t.Fatalf("parse pgn: %v\n%s", err, text)
+	}
+	if len(trees) != 1 {
+		t.Fatalf("got %d games, want 1", len(trees))
+	}
+	nodes := trees[0].MainLine()
+	if len(nodes) != len(g.Moves) {
+		t.Fatalf("got %d moves, want %d\n%s", len(nodes), len(g.Moves), text)
+	}
+	if got := trees[0].Root.Position.Variant; got != g.Variant() {
+		t.Fatalf("got variant %v, want %v", got, g.Variant())
+	}
+	for i, n := range nodes {
+		if n.Move != g.Moves[i].Move || n.SAN != g.Moves[i].SAN {
+			t.Fatalf("ply %d: got %s, want %s", i+1, n.SAN, g.Moves[i].SAN)
+		}
+		if got, want := n.Position.FEN(), g.PositionAt(i+1).FEN(); got != want {
+			t.Fatalf("ply %d %s: got %s, want %s", i+1, n.SAN, got, want)
+		}
+	}
+}
+
+func TestPGNRoundTripCrazyhouse(t *testing.T) {
+	drops := 0
+	for seed := int64(1); seed <= 20; seed++ {
+		g := randomGame(VariantCrazyhouse, seed, 120)
+		for _, m := range g.Moves {
+			if m.Move.Type == MoveTypeDrop {
+				drops++
+			}
+		}
+		checkPGNRoundTrip(t, g)
+	}
+	if drops == 0 {
+		t.Fatal("no game contains a drop")
+	}
+}
+
+func TestParsePGNCrazyhouseDrop(t *testing.T) {
+	text := `[Variant "Crazyhouse"]
+
+1. e4 d5 2. exd5 Qxd5 3. Nc3 Qa5 4. P@d5 *`
+	trees, err := ParsePGN(text)
+	if err != nil {
+		t.Fatalf("parse pgn: %v", err)
+	}
+	nodes := trees[0].MainLine()
+	last := nodes[len(nodes)-1]
+	if last.Move.Type != MoveTypeDrop || last.SAN != "P@d5" {
+		t.Fatalf("got %s, want drop P@d5", last.SAN)
+	}
+	if got := last.Position.Pockets[SideWhite][ChessPieceTypePawn]; got != 0 {
+		t.Errorf("white still has %d pawns in pocket", got)
+	}
+}
+
+func TestPGNRoundTripAtomic(t *testing.T) {
+	explosions := 0
+	for seed := int64(1); seed <= 20; seed++ {
+		g := randomGame(VariantAtomic, seed, 120)
+		for i, m := range g.Moves {
+			if g.PositionAt(i).Table.GetPosition(m.Move.ToX, m.Move.ToY) != nil {
+				explosions++
+			}
+		}
+		checkPGNRoundTrip(t, g)
+	}
+	if explosions == 0 {
+		t.Fatal("no game contains an explosion")
+	}
+}
+
+func TestParsePGNAtomicExplosion(t *testing.T) {
+	text := `[Variant "Atomic"]
+
+1. Nf3 a6 2. Ng5 a5 3. Nxf7 1-0`
+	trees, err := ParsePGN(text)
+	if err != nil {
+		t.Fatalf("parse pgn: %v", err)
+	}
+	nodes := trees[0].MainLine()
+	after := nodes[len(nodes)-1].Position.Table
+	if _, _, ok := after.findKing(SideBlack); ok {
+		t.Error("black king survived the explosion")
+	}
+	if p := after.GetPosition('f', 7); p != nil {
+		t.Errorf("capturing knight survived on f7: %v", p)
+	}
+}
@@ -0,0 +1,349 @@
+package chess
+
+import "strings"
+
+// 王车易位的权利, 每一位表示一种易位
+type CastlingRights uint8
+
+const (
+	CastlingWhiteKingside CastlingRights = 1 << iota
+	CastlingWhiteQueenside
+	CastlingBlackKingside
+	CastlingBlackQueenside
+
+	CastlingNone CastlingRights = 0
+	CastlingAll                 = CastlingWhiteKingside | CastlingWhiteQueenside | CastlingBlackKingside | CastlingBlackQueenside
+)
+
+// FEN中的写法, 比如KQkq, 没有任何权利时为-
+func (cr CastlingRights) String() string {
+	s := ""
+	if cr&CastlingWhiteKingside != 0 {
+		s += "K"
+	}
+	if cr&CastlingWhiteQueenside != 0 {
+		s += "Q"
+	}
+	if cr&CastlingBlackKingside != 0 {
+		s += "k"
+	}
+	if cr&CastlingBlackQueenside != 0 {
+		s += "q"
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// 某一方某一侧的易位权利
+func castlingRight(side Side, kingside bool) CastlingRights {
+	cr := CastlingWhiteKingside
+	if !kingside {
+		cr = CastlingWhiteQueenside
+	}
+	if side == SideBlack {
+		cr <<= 2
+	}
+	return cr
+}
+
+// 根据王和车的Moved推断易位权利, 只要王和王某一侧底线上的车都没动过就认为有这一侧的权利
+func (ct *ChessTable) CastlingRights() CastlingRights {
+	cr := CastlingNone
+	for _, side := range [2]Side{SideWhite, SideBlack} {
+		kingX, ok := ct.unmovedKingX(side)
+		if !ok {
+			continue
+		}
+		for _, kingside := range [2]bool{true, false} {
+			if _, ok := ct.castlingRookX(side, kingX, kingside); ok {
+				cr |= castlingRight(side, kingside)
+			}
+		}
+	}
+	return cr
+}
+
+// 根据兵的PawnMovedTwoLastTime推断过路兵的格子, 也就是轮到sideToMove走时可以吃过路兵走到的格子
+func (ct *ChessTable) EnPassantSquare(sideToMove Side) (rune, int, bool) {
+	mover := sideToMove.Opponent()
+	y := backRank(mover) + 3*pawnDirection(mover)
+	for x := 0; x < 8; x++ {
+		p := ct.GetIndex(x, y)
+		if p != nil && p.PieceType == ChessPieceTypePawn && p.GameSide == mover && p.PawnMovedTwoLastTime {
+			X, Y := indexToPosition(x, y-pawnDirection(mover))
+			return X, Y, true
+		}
+	}
+	return 0, 0, false
+}
+
+// 完整的局面, 把分散在棋子上的易位和过路兵状态提取出来, 方便比较, 哈希和序列化
+// 通过MakeMove修改局面时, 这些字段和Table上的棋子状态始终保持一致,
+// 直接修改这些字段后需要调用SyncTable把它们写回Table
+type Position struct {
+	Table      *ChessTable
+	SideToMove Side
+	Castling   CastlingRights
+
+	// 过路兵的格子, 没有时EnPassantX为0
+	EnPassantX rune
+	EnPassantY int
+
+	// 距离上一次吃子或者动兵走过的半回合数, 用于50步规则
+	HalfmoveClock int
+	// 从1开始, 黑方走完之后加一
+	FullmoveNumber int
+
+	// 局面的Zobrist哈希, MakeMove时增量更新
+	Hash uint64
+
+	// 对局使用的规则, 目前只有Crazyhouse和Atomic会影响走法
+	Variant Variant
+	// Crazyhouse中双方手里的棋子, 其他变体总是空的
+	Pockets Pockets
+}
+
+// 撤销Position.MakeMove需要的信息
+type PositionUndo struct {
+	Undo
+
+	castling       CastlingRights
+	enPassantX     rune
+	enPassantY     int
+	halfmoveClock  int
+	fullmoveNumber int
+	hash           uint64
+}
+
+// 标准开局
+func NewPosition() *Position {
+	return NewPositionFromTable(NewChessTable(), SideWhite)
+}
+
+// 从现有的棋盘构造局面, 易位权利和过路兵根据棋子的状态推断, 计数器从头开始
+func NewPositionFromTable(table *ChessTable, sideToMove Side) *Position {
+	p := &Position{
+		Table:          table,
+		SideToMove:     sideToMove,
+		Castling:       table.CastlingRights(),
+		FullmoveNumber: 1,
+	}
+	p.EnPassantX, p.EnPassantY, _ = table.EnPassantSquare(sideToMove)
+	p.Hash = p.ComputeHash()
+	return p
+}
+
+// 棋子摆放后面带有方括号时当作Crazyhouse的FEN, 比如 rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[Qn] w KQkq - 0 1
+func ParsePositionFEN(fen string) (*Position, error) {
+	var pockets *Pockets
+	if i, j := strings.IndexByte(fen, '['), strings.IndexByte(fen, ']'); i >= 0 && j > i {
+		pks, err := parseFENPockets(fen[i+1 : j])
+		if err != nil {
+			return nil, err
+		}
+		pockets = &pks
+		fen = fen[:i] + fen[j+1:]
+	}
+
+	table, side, halfmove, fullmove, err := ParseFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	p := NewPositionFromTable(table, side)
+	p.HalfmoveClock = halfmove
+	p.FullmoveNumber = fullmove
+	if pockets != nil {
+		p.Variant = VariantCrazyhouse
+		p.Pockets = *pockets
+		p.Hash = p.ComputeHash()
+	}
+	return p, nil
+}
+
+// Crazyhouse的棋子摆放后面用方括号附上双方手里的棋子, 升变来的棋子后面加上~
+func (p *Position) FEN() string {
+	fen := formatFEN(p.Table, p.SideToMove, p.Castling, p.EnPassantX, p.EnPassantY, p.HalfmoveClock, p.FullmoveNumber)
+	if p.Variant != VariantCrazyhouse {
+		return fen
+	}
+	_, rest, _ := strings.Cut(fen, " ")
+	return formatFENPlacement(p.Table, true) + "[" + p.Pockets.String() + "] " + rest
+}
+
+func (p *Position) Copy() *Position {
+	np := *p
+	np.Table = p.Table.Copy()
+	return &np
+}
+
+// 把易位权利和过路兵写回Table上的棋子, 保证wire上的ChessTable和Position一致, 同时重新计算哈希
+func (p *Position) SyncTable() {
+	for _, side := range [2]Side{SideWhite, SideBlack} {
+		y := backRank(side)
+		kingX := -1
+		for x := 0; x < 8; x++ {
+			if k := p.Table.GetIndex(x, y); k != nil && k.PieceType == ChessPieceTypeKing && k.GameSide == side {
+				kingX = x
+			}
+		}
+		if kingX < 0 {
+			continue
+		}
+		p.Table.GetIndex(kingX, y).Moved = p.Castling&(castlingRight(side, true)|castlingRight(side, false)) == 0
+
+		// 有权利的一侧优先保留原来没动过的车, 没有的话用离角最近的车, 这一侧其余的车都当作动过
+		for _, kingside := range [2]bool{true, false} {
+			rookX, ok := p.Table.castlingRookX(side, kingX, kingside)
+			x, step := 0, 1
+			if kingside {
+				x, step = 7, -1
+			}
+			for ; x != kingX; x += step {
+				rook := p.Table.GetIndex(x, y)
+				if rook == nil || rook.PieceType != ChessPieceTypeRook || rook.GameSide != side {
+					continue
+				}
+				if !ok {
+					rookX, ok = x, true
+				}
+				rook.Moved = x != rookX || p.Castling&castlingRight(side, kingside) == 0
+			}
+		}
+	}
+
+	for i := 0; i < 64; i++ {
+		if p.Table[i] != nil {
+			p.Table[i].PawnMovedTwoLastTime = false
+		}
+	}
+	if p.EnPassantX != 0 {
+		x, y := MustPositionToIndex(p.EnPassantX, p.EnPassantY)
+		mover := p.SideToMove.Opponent()
+		if pawn := p.Table.GetIndex(x, y+pawnDirection(mover)); pawn != nil && pawn.PieceType == ChessPieceTypePawn {
+			pawn.PawnMovedTwoLastTime = true
+		}
+	}
+
+	p.Hash = p.ComputeHash()
+}
+
+// 按照变体的规则生成, Crazyhouse中包括打入
+func (p *Position) LegalMoves() []Move {
+	switch p.Variant {
+	case VariantCrazyhouse:
+		return p.Table.appendDrops(p.Table.LegalMoves(p.SideToMove), p.SideToMove, &p.Pockets[p.SideToMove])
+	case VariantAtomic:
+		return p.Table.atomicLegalMoves(p.SideToMove)
+	default:
+		return p.Table.LegalMoves(p.SideToMove)
+	}
+}
+
+// 轮到的一方是否正在被将军
+func (p *Position) InCheck() bool {
+	if p.Variant == VariantAtomic {
+		return p.Table.atomicInCheck(p.SideToMove)
+	}
+	return p.Table.InCheck(p.SideToMove)
+}
+
+// Crazyhouse中被将军时可能还能通过打入解将, 所以要把打入也考虑进去
+func (p *Position) Status() GameStatus {
+	switch p.Variant {
+	case VariantCrazyhouse:
+		hasMove := p.Table.hasLegalMove(p.SideToMove) || len(p.Table.appendDrops(nil, p.SideToMove, &p.Pockets[p.SideToMove])) > 0
+		return gameStatus(p.InCheck(), hasMove)
+	case VariantAtomic:
+		return gameStatus(p.InCheck(), len(p.LegalMoves()) > 0)
+	default:
+		return p.Table.Status(p.SideToMove)
+	}
+}
+
+// 执行一步棋并更新易位权利, 过路兵, 计数器和轮到的一方
+func (p *Position) MakeMove(m Move) PositionUndo {
+	u := PositionUndo{
+		castling:       p.Castling,
+		enPassantX:     p.EnPassantX,
+		enPassantY:     p.EnPassantY,
+		halfmoveClock:  p.HalfmoveClock,
+		fullmoveNumber: p.FullmoveNumber,
+		hash:           p.Hash,
+	}
+	if m.Type == MoveTypeDrop {
+		return p.makeDrop(m, u)
+	}
+
+	fx, fy := MustPositionToIndex(m.FromX, m.FromY)
+	tx, ty := MustPositionToIndex(m.ToX, m.ToY)
+	piece := p.Table.GetIndex(fx, fy)
+	isPawn := piece.PieceType == ChessPieceTypePawn
+
+	h := p.Hash
+	h ^= zobristCastling[p.Castling] ^ zobristEnPassantKey(p.Table, p.SideToMove, p.EnPassantX, p.EnPassantY)
+	h ^= zobristPiece(piece, fy*8+fx)
+
+	u.Undo = p.Table.MakeMove(m)
+
+	if u.Captured != nil {
+		h ^= zobristPiece(u.Captured, u.capturedIndex)
+		if p.Variant == VariantCrazyhouse {
+			h ^= p.Pockets.put(p.SideToMove, pocketPieceType(u.Captured))
+		}
+	}
+	h ^= zobristPiece(p.Table.GetIndex(tx, ty), ty*8+tx)
+	if u.rook != nil {
+		h ^= zobristPiece(u.rook, fy*8+u.rookFromX) ^ zobristPiece(u.rook, fy*8+u.rookToX)
+	}
+
+	if p.Variant == VariantAtomic && u.Captured != nil {
+		p.Table.explode(&u.Undo)
+		for _, e := range u.exploded {
+			ex, ey := MustPositionToIndex(e.X, e.Y)
+			h ^= zobristPiece(e, ey*8+ex)
+		}
+	}
+
+	// 只有底线上的王和车移动, 被吃或者被炸掉时才会失去易位权利
+	if fy == 0 || fy == 7 || ty == 0 || ty == 7 || len(u.exploded) > 0 {
+		p.Castling &= p.Table.CastlingRights()
+	}
+
+	p.EnPassantX, p.EnPassantY = 0, 0
+	if isPawn && (ty-fy == 2 || fy-ty == 2) {
+		p.EnPassantX, p.EnPassantY = indexToPosition(fx, (fy+ty)/2)
+	}
+
+	if isPawn || u.Captured != nil {
+		p.HalfmoveClock = 0
+	} else {
+		p.HalfmoveClock++
+	}
+	if p.SideToMove == SideBlack {
+		p.FullmoveNumber++
+	}
+	p.SideToMove = p.SideToMove.Opponent()
+
+	h ^= zobristCastling[p.Castling] ^ zobristEnPassantKey(p.Table, p.SideToMove, p.EnPassantX, p.EnPassantY)
+	h ^= zobristBlackMove
+	p.Hash = h
+
+	return u
+}
+
+func (p *Position) UnmakeMove(u PositionUndo) {
+	p.Table.UnmakeMove(u.Undo)
+	p.SideToMove = p.SideToMove.Opponent()
+	if u.Move.Type == MoveTypeDrop {
+		p.Pockets[p.SideToMove][u.Move.Drop]++
+	} else if p.Variant == VariantCrazyhouse && u.Captured != nil {
+		p.Pockets[p.SideToMove][pocketPieceType(u.Captured)]--
+	}
+	p.Castling = u.castling
+	p.EnPassantX, p.EnPassantY = u.enPassantX, u.enPassantY
+	p.HalfmoveClock = u.halfmoveClock
+	p.FullmoveNumber = u.fullmoveNumber
+	p.Hash = u.hash
+}
@@ -0,0 +1,256 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidSAN = errors.New("invalid san")
+
+// 把一步合法的棋转换成标准代数记法, 比如Nxf7+, O-O, e8=Q#
+func (ct *ChessTable) MoveToSAN(m Move) string {
+	piece := ct.GetPosition(m.FromX, m.FromY)
+	san := ct.sanWithoutSuffix(m, piece, ct.LegalMoves(piece.GameSide))
+
+	u := ct.MakeMove(m)
+	defer ct.UnmakeMove(u)
+	switch ct.Status(piece.GameSide.Opponent()) {
+	case GameStatusCheckmate:
+		san += "#"
+	case GameStatusCheck:
+		san += "+"
+	}
+	return san
+}
+
+// 和ChessTable.MoveToSAN相同, 但是按照变体的规则判断消歧义和将死, 也支持打入, 比如N@e4
+func (p *Position) MoveToSAN(m Move) string {
+	var san string
+	if m.Type == MoveTypeDrop {
+		san = m.String()
+	} else {
+		san = p.Table.sanWithoutSuffix(m, p.Table.GetPosition(m.FromX, m.FromY), p.LegalMoves())
+	}
+
+	u := p.MakeMove(m)
+	defer p.UnmakeMove(u)
+	switch p.Status() {
+	case GameStatusCheckmate:
+		san += "#"
+	case GameStatusCheck:
+		san += "+"
+	}
+	return san
+}
+
+// legal是这一方所有合法的着法, 用来判断是否需要消歧义
+func (ct *ChessTable) sanWithoutSuffix(m Move, piece *ChessPiece, legal []Move) string {
+	if m.Type == MoveTypeCastling {
+		if isKingsideCastling(m) {
+			return "O-O"
+		}
+		return "O-O-O"
+	}
+
+	capture := m.Type == MoveTypeEnPassant || ct.GetPosition(m.ToX, m.ToY) != nil
+	var sb strings.Builder
+
+	if piece.PieceType == ChessPieceTypePawn {
+		if capture {
+			sb.WriteRune(m.FromX)
+		}
+	} else {
+		sb.WriteRune(pieceTypeLetter(piece.PieceType))
+		sb.WriteString(ct.sanDisambiguation(m, piece, legal))
+	}
+
+	if capture {
+		sb.WriteByte('x')
+	}
+	fmt.Fprintf(&sb, "%c%d", m.ToX, m.ToY)
+
+	if m.Type == MoveTypePromotion {
+		sb.WriteByte('=')
+		sb.WriteRune(pieceTypeLetter(m.Promotion))
+	}
+	return sb.String()
+}
+
+// 有同类棋子也能走到同一个格子时, 依次尝试用列, 行, 列加行来区分
+func (ct *ChessTable) sanDisambiguation(m Move, piece *ChessPiece, legal []Move) string {
+	sameFile, sameRank, ambiguous := false, false, false
+	for _, other := range legal {
+		if other.Type == MoveTypeDrop || other.ToX != m.ToX || other.ToY != m.ToY || (other.FromX == m.FromX && other.FromY == m.FromY) {
+			continue
+		}
+		if ct.GetPosition(other.FromX, other.FromY).PieceType != piece.PieceType {
+			continue
+		}
+		ambiguous = true
+		if other.FromX == m.FromX {
+			sameFile = true
+		}
+		if other.FromY == m.FromY {
+			sameRank = true
+		}
+	}
+
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return string(m.FromX)
+	case !sameRank:
+		return fmt.Sprint(m.FromY)
+	default:
+		return fmt.Sprintf("%c%d", m.FromX, m.FromY)
+	}
+}
+
+// 解析side方的一步标准代数记法, 结尾的+, #, !, ?会被忽略
+// 也接受0-0和0-0-0, 省略了=的升变比如a8Q, 以及吃过路兵后面的e.p., 比如exd6e.p.
+func (ct *ChessTable) ParseSAN(san string, side Side) (Move, error) {
+	return ct.parseSAN(san, ct.LegalMoves(side))
+}
+
+// legal是这一方所有合法的着法, 只在这些着法中查找
+func (ct *ChessTable) parseSAN(san string, legal []Move) (Move, error) {
+	s := strings.TrimRight(san, "+#!?")
+	s = strings.TrimSuffix(s, "e.p.")
+	if s == "" {
+		return Move{}, fmt.Errorf("%w: empty move", ErrInvalidSAN)
+	}
+
+	if s == "O-O" || s == "0-0" || s == "O-O-O" || s == "0-0-0" {
+		long := len(s) == 5
+		for _, m := range legal {
+			if m.Type == MoveTypeCastling && isKingsideCastling(m) != long {
+				return m, nil
+			}
+		}
+		return Move{}, fmt.Errorf("%w: %q is not legal", ErrInvalidSAN, san)
+	}
+
+	// 升变
+	promotion := ChessPieceType(-1)
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		if i != len(s)-2 {
+			return Move{}, fmt.Errorf("%w: bad promotion in %q", ErrInvalidSAN, san)
+		}
+		t, ok := letterToPieceType(rune(s[i+1]))
+		if !ok || t == ChessPieceTypeKing || t == ChessPieceTypePawn {
+			return Move{}, fmt.Errorf("%w: bad promotion in %q", ErrInvalidSAN, san)
+		}
+		promotion = t
+		s = s[:i]
+	} else if n := len(s); n >= 3 && s[n-2] >= '1' && s[n-2] <= '8' && strings.IndexByte("QRBN", s[n-1]) >= 0 {
+		// 省略了=的升变
+		promotion, _ = letterToPieceType(rune(s[n-1]))
+		s = s[:n-1]
+	}
+
+	pieceType := ChessPieceTypePawn
+	if len(s) > 0 && s[0] >= 'A' && s[0] <= 'Z' {
+		t, ok := letterToPieceType(rune(s[0]))
+		if !ok || t == ChessPieceTypePawn {
+			return Move{}, fmt.Errorf("%w: bad piece in %q", ErrInvalidSAN, san)
+		}
+		pieceType = t
+		s = s[1:]
+	}
+
+	s = strings.Replace(s, "x", "", 1)
+	if len(s) < 2 {
+		return Move{}, fmt.Errorf("%w: missing target square in %q", ErrInvalidSAN, san)
+	}
+	toX, toY, ok := parseSquare(s[len(s)-2:])
+	if !ok {
+		return Move{}, fmt.Errorf("%w: bad target square in %q", ErrInvalidSAN, san)
+	}
+
+	// 剩下的部分是消歧义用的列或行
+	var fromX rune
+	var fromY int
+	for _, r := range s[:len(s)-2] {
+		switch {
+		case r >= 'a' && r <= 'h' && fromX == 0:
+			fromX = r
+		case r >= '1' && r <= '8' && fromY == 0:
+			fromY = int(r - '0')
+		default:
+			return Move{}, fmt.Errorf("%w: bad disambiguation in %q", ErrInvalidSAN, san)
+		}
+	}
+
+	var found *Move
+	for i := range legal {
+		m := &legal[i]
+		if m.ToX != toX || m.ToY != toY || m.Type == MoveTypeCastling || m.Type == MoveTypeDrop {
+			continue
+		}
+		if ct.GetPosition(m.FromX, m.FromY).PieceType != pieceType {
+			continue
+		}
+		if (fromX != 0 && m.FromX != fromX) || (fromY != 0 && m.FromY != fromY) {
+			continue
+		}
+		if (m.Type == MoveTypePromotion) != (promotion >= 0) {
+			continue
+		}
+		if m.Type == MoveTypePromotion && m.Promotion != promotion {
+			continue
+		}
+		if found != nil {
+			return Move{}, fmt.Errorf("%w: %q is ambiguous", ErrInvalidSAN, san)
+		}
+		found = m
+	}
+
+	if found == nil {
+		return Move{}, fmt.Errorf("%w: %q is not legal", ErrInvalidSAN, san)
+	}
+	return *found, nil
+}
+
+// 和ChessTable.ParseSAN相同, 但是按照变体的规则查找, 也支持打入, 兵的打入可以省略P, 比如@e4
+func (p *Position) ParseSAN(san string) (Move, error) {
+	s := strings.TrimRight(san, "+#!?")
+	i := strings.IndexByte(s, '@')
+	if i < 0 {
+		return p.Table.parseSAN(san, p.LegalMoves())
+	}
+
+	m := Move{Type: MoveTypeDrop, Drop: ChessPieceTypePawn}
+	switch i {
+	case 0:
+	case 1:
+		t, ok := letterToPieceType(rune(s[0]))
+		if !ok || t == ChessPieceTypeKing || s[0] < 'A' || s[0] > 'Z' {
+			return Move{}, fmt.Errorf("%w: bad piece in %q", ErrInvalidSAN, san)
+		}
+		m.Drop = t
+	default:
+		return Move{}, fmt.Errorf("%w: bad drop in %q", ErrInvalidSAN, san)
+	}
+	toX, toY, ok := parseSquare(s[i+1:])
+	if !ok {
+		return Move{}, fmt.Errorf("%w: bad target square in %q", ErrInvalidSAN, san)
+	}
+	m.ToX, m.ToY = toX, toY
+
+	for _, lm := range p.LegalMoves() {
+		if lm == m {
+			return m, nil
+		}
+	}
+	return Move{}, fmt.Errorf("%w: %q is not legal", ErrInvalidSAN, san)
+}
+
+// 解析e4这样的格子
+func parseSquare(s string) (rune, int, bool) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return 0, 0, false
+	}
+	return rune(s[0]), int(s[1] - '0'), true
+}
@@ -0,0 +1,61 @@
+package chess
+
+type GameStatus int
+
+const (
+	// 正常, 没有被将军
+	GameStatusNormal GameStatus = iota
+	// 被将军, 但是还有合法的走法
+	GameStatusCheck
+	// 被将死
+	GameStatusCheckmate
+	// 逼和, 没有被将军但是无棋可走
+	GameStatusStalemate
+)
+
+// side方的王是否正在被将军, 没有王时返回false
+func (ct *ChessTable) InCheck(side Side) bool {
+	kx, ky, ok := ct.findKing(side)
+	if !ok {
+		return false
+	}
+	return ct.isSquareAttacked(kx, ky, side.Opponent())
+}
+
+// side方是否至少有一步合法的走法, 找到一步就返回
+func (ct *ChessTable) hasLegalMove(side Side) bool {
+	for _, m := range ct.pseudoLegalMoves(side) {
+		if ct.leavesKingSafe(m, side) {
+			return true
+		}
+	}
+	return false
+}
+
+// 轮到side方走时, 是否已经被将死
+func (ct *ChessTable) IsCheckmate(side Side) bool {
+	return ct.InCheck(side) && !ct.hasLegalMove(side)
+}
+
+// 轮到side方走时, 是否逼和
+func (ct *ChessTable) IsStalemate(side Side) bool {
+	return !ct.InCheck(side) && !ct.hasLegalMove(side)
+}
+
+// 轮到side方走时的局面状态
+func (ct *ChessTable) Status(side Side) GameStatus {
+	return gameStatus(ct.InCheck(side), ct.hasLegalMove(side))
+}
+
+func gameStatus(inCheck bool, hasMove bool) GameStatus {
+	switch {
+	case inCheck && !hasMove:
+		return GameStatusCheckmate
+	case !hasMove:
+		return GameStatusStalemate
+	case inCheck:
+		return GameStatusCheck
+	default:
+		return GameStatusNormal
+	}
+}
@@ -0,0 +1,155 @@
+package chess
+
+import "fmt"
+
+type ValidationErrorCode int
+
+const (
+	// 某一方没有王
+	ValidationErrorMissingKing ValidationErrorCode = iota
+	// 某一方有不止一个王
+	ValidationErrorDuplicateKing
+	// 兵在第一行或者第八行
+	ValidationErrorPawnOnBackRank
+	// 不该走的一方正在被将军
+	ValidationErrorOpponentInCheck
+	// 没有移动过的王或车不在底线上
+	ValidationErrorImpossibleCastling
+	// 刚走了两格的兵不在对应的行上, 或者不是兵
+	ValidationErrorImpossibleEnPassant
+	// 棋子的X, Y和它在数组中的位置不一致
+	ValidationErrorPositionMismatch
+	// 棋子的类型或者所属的一方不存在
+	ValidationErrorInvalidPiece
+)
+
+// 棋盘上的一个错误, X为0表示和具体的格子无关
+type ValidationError struct {
+	Code ValidationErrorCode
+	Side Side
+	X    rune
+	Y    int
+}
+
+func (e *ValidationError) Error() string {
+	var s string
+	switch e.Code {
+	case ValidationErrorMissingKing:
+		s = "missing king"
+	case ValidationErrorDuplicateKing:
+		s = "duplicate king"
+	case ValidationErrorPawnOnBackRank:
+		s = "pawn on first or eighth rank"
+	case ValidationErrorOpponentInCheck:
+		s = "side not to move is in check"
+	case ValidationErrorImpossibleCastling:
+		s = "unmoved king or rook outside its back rank"
+	case ValidationErrorImpossibleEnPassant:
+		s = "impossible en passant flag"
+	case ValidationErrorPositionMismatch:
+		s = "piece position disagrees with its square"
+	case ValidationErrorInvalidPiece:
+		s = "invalid piece"
+	default:
+		s = "unknown error"
+	}
+
+	side := "white"
+	if e.Side == SideBlack {
+		side = "black"
+	}
+	if e.X == 0 {
+		return fmt.Sprintf("%s: %s", side, s)
+	}
+	return fmt.Sprintf("%s at %c%d: %s", side, e.X, e.Y, s)
+}
+
+// 和MustPositionToIndex相同, 但是不会panic
+func PositionToIndex(X rune, Y int) (int, int, bool) {
+	if X < 'a' || X > 'h' || Y < 1 || Y > 8 {
+		return 0, 0, false
+	}
+	return int(X - 'a'), Y - 1, true
+}
+
+// 检查棋盘是否合理, 轮到sideToMove走, 没有问题时返回nil
+// 每个格子和每一方的问题都会单独报告, 只有棋盘结构没问题时才会检查将军
+func (ct *ChessTable) Validate(sideToMove Side) []*ValidationError {
+	var errs []*ValidationError
+	kings := [2]int{}
+
+	for i := 0; i < 64; i++ {
+		p := ct[i]
+		if p == nil {
+			continue
+		}
+		x, y := i%8, i/8
+		X, Y := indexToPosition(x, y)
+
+		if p.PieceType < ChessPieceTypeRook || p.PieceType > ChessPieceTypePawn ||
+			(p.GameSide != SideWhite && p.GameSide != SideBlack) {
+			errs = append(errs, &ValidationError{Code: ValidationErrorInvalidPiece, Side: p.GameSide, X: X, Y: Y})
+			continue
+		}
+
+		if px, py, ok := PositionToIndex(p.X, p.Y); !ok || px != x || py != y {
+			errs = append(errs, &ValidationError{Code: ValidationErrorPositionMismatch, Side: p.GameSide, X: X, Y: Y})
+		}
+
+		switch p.PieceType {
+		case ChessPieceTypeKing:
+			kings[p.GameSide]++
+			if !p.Moved && y != backRank(p.GameSide) {
+				errs = append(errs, &ValidationError{Code: ValidationErrorImpossibleCastling, Side: p.GameSide, X: X, Y: Y})
+			}
+		case ChessPieceTypeRook:
+			if !p.Moved && y != backRank(p.GameSide) {
+				errs = append(errs, &ValidationError{Code: ValidationErrorImpossibleCastling, Side: p.GameSide, X: X, Y: Y})
+			}
+		case ChessPieceTypePawn:
+			if y == 0 || y == 7 {
+				errs = append(errs, &ValidationError{Code: ValidationErrorPawnOnBackRank, Side: p.GameSide, X: X, Y: Y})
+			}
+		}
+
+		if p.PawnMovedTwoLastTime && (p.PieceType != ChessPieceTypePawn || p.GameSide == sideToMove ||
+			y != backRank(p.GameSide)+3*pawnDirection(p.GameSide)) {
+			errs = append(errs, &ValidationError{Code: ValidationErrorImpossibleEnPassant, Side: p.GameSide, X: X, Y: Y})
+		}
+	}
+
+	for _, side := range [2]Side{SideWhite, SideBlack} {
+		switch {
+		case kings[side] == 0:
+			errs = append(errs, &ValidationError{Code: ValidationErrorMissingKing, Side: side})
+		case kings[side] > 1:
+			errs = append(errs, &ValidationError{Code: ValidationErrorDuplicateKing, Side: side})
+		}
+	}
+
+	if len(errs) == 0 && ct.InCheck(sideToMove.Opponent()) {
+		errs = append(errs, &ValidationError{Code: ValidationErrorOpponentInCheck, Side: sideToMove.Opponent()})
+	}
+
+	return errs
+}
+
+// 和Validate相同, 但是考虑变体的规则
+// Atomic中王可能已经被炸掉, 炸掉对方的王的一步可以让自己留在将军中, 双方的王相邻时也不算将军
+func (ct *ChessTable) ValidateVariant(sideToMove Side, v Variant) []*ValidationError {
+	errs := ct.Validate(sideToMove)
+	if v != VariantAtomic {
+		return errs
+	}
+
+	var kept []*ValidationError
+	for _, e := range errs {
+		if e.Code != ValidationErrorMissingKing && e.Code != ValidationErrorOpponentInCheck {
+			kept = append(kept, e)
+		}
+	}
+	if _, _, ok := ct.findKing(sideToMove); ok && len(kept) == 0 && ct.atomicInCheck(sideToMove.Opponent()) {
+		kept = append(kept, &ValidationError{Code: ValidationErrorOpponentInCheck, Side: sideToMove.Opponent()})
+	}
+	return kept
+}
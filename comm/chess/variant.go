@@ -0,0 +1,118 @@
+package chess
+
+import "strings"
+
+// 对局使用的规则
+type Variant int
+
+const (
+	VariantStandard Variant = iota
+	// 底线的棋子随机排列, 易位规则也相应推广
+	VariantChess960
+	// 王走到中心的四个格子就获胜
+	VariantKingOfTheHill
+	// 第三次将军的一方获胜
+	VariantThreeCheck
+	// 吃掉的棋子进入自己手里, 之后可以代替走棋打入到空格子上
+	VariantCrazyhouse
+	// 吃子时引发爆炸, 炸掉对方的王就获胜
+	VariantAtomic
+)
+
+// Crazyhouse的标准开局, 双方手里都没有棋子
+const crazyhouseStartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1"
+
+// 变体的标准开局, 开始局面和它不同时PGN需要带上FEN
+func (v Variant) startFEN() string {
+	if v == VariantCrazyhouse {
+		return crazyhouseStartFEN
+	}
+	return StartFEN
+}
+
+// PGN中Variant标签的值
+func (v Variant) String() string {
+	switch v {
+	case VariantChess960:
+		return "Chess960"
+	case VariantKingOfTheHill:
+		return "King of the Hill"
+	case VariantThreeCheck:
+		return "Three-check"
+	case VariantCrazyhouse:
+		return "Crazyhouse"
+	case VariantAtomic:
+		return "Atomic"
+	default:
+		return "Standard"
+	}
+}
+
+// 解析PGN中Variant标签的值, 不区分大小写, 标签为空或者是From Position时当作标准规则
+func ParseVariant(s string) (Variant, bool) {
+	switch strings.ToLower(s) {
+	case "", "standard", "from position":
+		return VariantStandard, true
+	}
+	for v := VariantChess960; v <= VariantAtomic; v++ {
+		if strings.EqualFold(s, v.String()) {
+			return v, true
+		}
+	}
+	return VariantStandard, false
+}
+
+// 变体在标准规则之上的结束条件, 打入和爆炸这种走法上的不同由Position根据Variant处理
+type VariantRules interface {
+	// 刚走完一步之后变体特有的胜负, 没有结束时返回GameResultOngoing
+	// 在判定将死和逼和之前调用
+	Outcome(g *Game) (GameResult, GameResultReason)
+}
+
+func (v Variant) Rules() VariantRules {
+	switch v {
+	case VariantKingOfTheHill:
+		return kingOfTheHillRules{}
+	case VariantThreeCheck:
+		return threeCheckRules{}
+	case VariantAtomic:
+		return atomicRules{}
+	default:
+		return standardRules{}
+	}
+}
+
+// 标准国际象棋, Chess960和Crazyhouse没有额外的结束条件
+type standardRules struct{}
+
+func (standardRules) Outcome(g *Game) (GameResult, GameResultReason) {
+	return GameResultOngoing, GameResultReasonNone
+}
+
+type kingOfTheHillRules struct{}
+
+// d4, e4, d5, e5
+func isCenterSquare(x int, y int) bool {
+	return (x == 3 || x == 4) && (y == 3 || y == 4)
+}
+
+func (kingOfTheHillRules) Outcome(g *Game) (GameResult, GameResultReason) {
+	mover := g.SideToMove().Opponent()
+	if x, y, ok := g.Position.Table.findKing(mover); ok && isCenterSquare(x, y) {
+		return GameResultFromWinner(mover), GameResultReasonKingOfTheHill
+	}
+	return GameResultOngoing, GameResultReasonNone
+}
+
+// 获胜需要的将军次数
+const threeCheckLimit = 3
+
+type threeCheckRules struct{}
+
+func (threeCheckRules) Outcome(g *Game) (GameResult, GameResultReason) {
+	mover := g.SideToMove().Opponent()
+	if g.Checks(mover) >= threeCheckLimit {
+		return GameResultFromWinner(mover), GameResultReasonThreeCheck
+	}
+	return GameResultOngoing, GameResultReasonNone
+}
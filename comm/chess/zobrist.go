@@ -0,0 +1,120 @@
+package chess
+
+// Zobrist哈希用到的随机数, 由固定种子生成, 保证客户端和服务端算出来的哈希一致
+var (
+	zobristPieces    [2][6][64]uint64
+	zobristCastling  [16]uint64
+	zobristEnPassant [8]uint64
+	zobristBlackMove uint64
+	// Crazyhouse手里的棋子, 某种棋子的数量从n变成n+1时异或上第n个
+	zobristPockets [2][6][16]uint64
+	// Three-check中一方已经将军了n次时异或上第n-1个, 只用在对局历史里
+	zobristChecks [2][threeCheckLimit]uint64
+)
+
+func init() {
+	// splitmix64, 不依赖math/rand的实现
+	seed := uint64(0x9e3779b97f4a7c15)
+	next := func() uint64 {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		return z ^ (z >> 31)
+	}
+
+	for side := 0; side < 2; side++ {
+		for t := 0; t < 6; t++ {
+			for i := 0; i < 64; i++ {
+				zobristPieces[side][t][i] = next()
+			}
+		}
+	}
+	for i := range zobristCastling {
+		zobristCastling[i] = next()
+	}
+	for i := range zobristEnPassant {
+		zobristEnPassant[i] = next()
+	}
+	zobristBlackMove = next()
+	// 放在最后生成, 这样其他的随机数和以前一样
+	for side := 0; side < 2; side++ {
+		for t := 0; t < 6; t++ {
+			for n := range zobristPockets[side][t] {
+				zobristPockets[side][t][n] = next()
+			}
+		}
+	}
+	for side := 0; side < 2; side++ {
+		for n := range zobristChecks[side] {
+			zobristChecks[side][n] = next()
+		}
+	}
+}
+
+func zobristPiece(p *ChessPiece, index int) uint64 {
+	return zobristPieces[p.GameSide][p.PieceType][index]
+}
+
+func zobristPocket(side Side, t ChessPieceType, n int) uint64 {
+	if n >= len(zobristPockets[side][t]) {
+		return 0
+	}
+	return zobristPockets[side][t][n]
+}
+
+func zobristCheckCount(side Side, n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	if n > threeCheckLimit {
+		n = threeCheckLimit
+	}
+	return zobristChecks[side][n-1]
+}
+
+// 只有轮到的一方真的有兵可以吃过路兵时, 过路兵才参与哈希,
+// 否则两个实际相同的局面会因为对方刚走了两格兵而得到不同的哈希
+func zobristEnPassantKey(ct *ChessTable, sideToMove Side, epX rune, epY int) uint64 {
+	if epX == 0 {
+		return 0
+	}
+	x, y := MustPositionToIndex(epX, epY)
+	py := y - pawnDirection(sideToMove)
+	for _, dx := range [2]int{-1, 1} {
+		if !onBoard(x+dx, py) {
+			continue
+		}
+		p := ct.GetIndex(x+dx, py)
+		if p != nil && p.PieceType == ChessPieceTypePawn && p.GameSide == sideToMove {
+			return zobristEnPassant[x]
+		}
+	}
+	return 0
+}
+
+func zobristHash(ct *ChessTable, sideToMove Side, castling CastlingRights, epX rune, epY int) uint64 {
+	var h uint64
+	for i := 0; i < 64; i++ {
+		if ct[i] != nil {
+			h ^= zobristPiece(ct[i], i)
+		}
+	}
+	h ^= zobristCastling[castling]
+	h ^= zobristEnPassantKey(ct, sideToMove, epX, epY)
+	if sideToMove == SideBlack {
+		h ^= zobristBlackMove
+	}
+	return h
+}
+
+// 计算棋盘的Zobrist哈希, 易位权利和过路兵根据棋子的状态推断
+func (ct *ChessTable) ZobristHash(sideToMove Side) uint64 {
+	epX, epY, _ := ct.EnPassantSquare(sideToMove)
+	return zobristHash(ct, sideToMove, ct.CastlingRights(), epX, epY)
+}
+
+// 根据当前的字段重新计算完整的哈希, 直接修改Position的字段后需要调用
+func (p *Position) ComputeHash() uint64 {
+	return zobristHash(p.Table, p.SideToMove, p.Castling, p.EnPassantX, p.EnPassantY) ^ p.Pockets.hash()
+}
@@ -21,10 +21,16 @@ func ClientParse(bs []byte) interface{} {
 	case PacketTypeServerGameOver:
 		p := PacketServerGameOver{}
 		json.Unmarshal(bs, &p)
+		if !decodeCompactTable(&p.Table, p.TableCompact) {
+			return nil
+		}
 		return &p
 	case PacketTypeServerMatchedOK:
 		p := PacketServerMatchedOK{}
 		json.Unmarshal(bs, &p)
+		if !decodeCompactTable(&p.Table, p.TableCompact) {
+			return nil
+		}
 		return &p
 	case PacketTypeServerMatching:
 		p := PacketServerMatching{}
@@ -33,6 +39,9 @@ func ClientParse(bs []byte) interface{} {
 	case PacketTypeServerMoveResp:
 		p := PacketServerMoveResp{}
 		json.Unmarshal(bs, &p)
+		if !decodeCompactTable(&p.TableOnOK, p.TableOnOKCompact) {
+			return nil
+		}
 		return &p
 	case PacketTypeServerRemoteLoseConnection:
 		p := PacketServerRemoteLoseConnection{}
@@ -41,14 +50,23 @@ func ClientParse(bs []byte) interface{} {
 	case PacketTypeServerNotifyRemoteMove:
 		p := PacketServerNotifyRemoteMove{}
 		json.Unmarshal(bs, &p)
+		if !decodeCompactTable(&p.Table, p.TableCompact) {
+			return nil
+		}
 		return &p
 	case PacketTypeServerRemoteUpgradeOK:
 		p := PacketServerRemoteUpgradeOK{}
 		json.Unmarshal(bs, &p)
+		if !decodeCompactTable(&p.Table, p.TableCompact) {
+			return nil
+		}
 		return &p
 	case PacketTypeServerUpgradeOK:
 		p := PacketServerUpgradeOK{}
 		json.Unmarshal(bs, &p)
+		if !decodeCompactTable(&p.Table, p.TableCompact) {
+			return nil
+		}
 		return &p
 	default:
 		return nil
@@ -0,0 +1,52 @@
+package packets
+
+import "chess-frontend/comm/chess"
+
+// 协商好紧凑编码之后, 服务端在发送前调用Compact, 把棋盘换成紧凑的字符串
+// ClientParse收到后会自动解码回Table, 所以客户端的逻辑不需要区分两种编码
+
+func compactTable(table **chess.ChessTable, compact *string) {
+	if *table == nil {
+		return
+	}
+	*compact = chess.EncodeTableCompact(*table)
+	*table = nil
+}
+
+// 有紧凑编码时解码到table里, 编码错误时返回false
+func decodeCompactTable(table **chess.ChessTable, compact string) bool {
+	if compact == "" {
+		return true
+	}
+	t, err := chess.DecodeTableCompact(compact)
+	if err != nil {
+		return false
+	}
+	*table = t
+	return true
+}
+
+func (p *PacketServerMatchedOK) Compact() {
+	p.CompactTable = true
+	compactTable(&p.Table, &p.TableCompact)
+}
+
+func (p *PacketServerMoveResp) Compact() {
+	compactTable(&p.TableOnOK, &p.TableOnOKCompact)
+}
+
+func (p *PacketServerGameOver) Compact() {
+	compactTable(&p.Table, &p.TableCompact)
+}
+
+func (p *PacketServerNotifyRemoteMove) Compact() {
+	compactTable(&p.Table, &p.TableCompact)
+}
+
+func (p *PacketServerRemoteUpgradeOK) Compact() {
+	compactTable(&p.Table, &p.TableCompact)
+}
+
+func (p *PacketServerUpgradeOK) Compact() {
+	compactTable(&p.Table, &p.TableCompact)
+}
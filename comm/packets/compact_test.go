@@ -0,0 +1,49 @@
+package packets
+
+import (
+	"chess-frontend/comm/chess"
+	"encoding/json"
+	"testing"
+)
+
+func TestCompactTableClientParse(t *testing.T) {
+	table := chess.NewChessTable()
+	table.MakeMove(chess.Move{Type: chess.MoveTypeNormal, FromX: 'e', FromY: 2, ToX: 'e', ToY: 4})
+
+	p := PacketServerNotifyRemoteMove{Table: table, KingThreat: true}
+	p.Compact()
+	if p.Table != nil || p.TableCompact == "" {
+		t.Fatalf("Compact left table %v, compact %q", p.Table, p.TableCompact)
+	}
+
+	got, ok := ClientParse(p.MustMarshalToBytes()).(*PacketServerNotifyRemoteMove)
+	if !ok {
+		t.Fatal("ClientParse did not return a remote move packet")
+	}
+	if got.Table == nil || got.Table.FEN(chess.SideBlack, 0, 1) != table.FEN(chess.SideBlack, 0, 1) || !got.KingThreat {
+		t.Errorf("got table %v, king threat %v", got.Table, got.KingThreat)
+	}
+	if pawn := got.Table.GetPosition('e', 4); pawn == nil || !pawn.PawnMovedTwoLastTime || !pawn.Moved {
+		t.Errorf("pawn state lost: %+v", pawn)
+	}
+}
+
+// 紧凑编码有错误时整个包都被丢弃
+func TestClientParseBadCompactTable(t *testing.T) {
+	packets := []interface{ MustMarshalToBytes() []byte }{
+		&PacketServerMatchedOK{CompactTable: true, TableCompact: "8/8/8/8/8/8/8/8 zz 0"},
+		&PacketServerMoveResp{TableOnOKCompact: "not a table"},
+		&PacketServerGameOver{TableCompact: "8/8/8/8/8/8/8/8 1 0"},
+		&PacketServerNotifyRemoteMove{TableCompact: "8/8/8/8/8/8/8/8 0"},
+		&PacketServerRemoteUpgradeOK{TableCompact: "8/8/8/8/8/8/8 0 0"},
+		&PacketServerUpgradeOK{TableCompact: "8/8/8/8/8/8/8/8 0 0 0 0"},
+	}
+	for _, p := range packets {
+		bs := p.MustMarshalToBytes()
+		if got := ClientParse(bs); got != nil {
+			var header PacketHeader
+			json.Unmarshal(bs, &header)
+			t.Errorf("packet type %d: got %T, want nil", *header.Type, got)
+		}
+	}
+}
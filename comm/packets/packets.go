@@ -77,6 +77,10 @@ func (p *PacketHeartbeat) MustMarshalToBytes() []byte {
 
 type PacketClientStartMatch struct {
 	PacketHeader
+	// 请求服务端使用紧凑的棋盘编码, 服务端在MatchedOK中确认
+	CompactTable bool `json:"compact_table,omitempty"`
+	// 想要下的变体, 只会和选择了相同变体的玩家匹配
+	Variant chess.Variant `json:"variant,omitempty"`
 }
 
 func (p *PacketClientStartMatch) MustMarshalToBytes() []byte {
@@ -109,6 +113,13 @@ type PacketServerMatchedOK struct {
 	PacketHeader
 	Side  chess.Side        `json:"game_side"`
 	Table *chess.ChessTable `json:"game_table"`
+	// 服务端同意使用紧凑的棋盘编码, 之后的包都只带TableCompact
+	CompactTable bool   `json:"compact_table,omitempty"`
+	TableCompact string `json:"game_table_compact,omitempty"`
+	// 双方使用的变体, Table是按照这个变体生成的开局
+	Variant chess.Variant `json:"variant,omitempty"`
+	// Crazyhouse中双方手里的棋子, 客户端以它为准, 旧的服务端不会发送
+	Pockets *chess.Pockets `json:"pockets,omitempty"`
 }
 
 func (p *PacketServerMatchedOK) MustMarshalToBytes() []byte {
@@ -131,6 +142,13 @@ type PacketClientMove struct {
 
 	// 和棋
 	DoDraw bool `json:"do_draw"`
+
+	// Crazyhouse中打入的棋子, 这时From被忽略, 其他时候为nil
+	Drop *chess.ChessPieceType `json:"drop,omitempty"`
+
+	// 王车易位, From/To是王的起点和终点
+	// Chess960中易位可能和王的普通移动坐标相同, 王甚至可能不动, 所以不能只靠坐标判断
+	Castling bool `json:"castling,omitempty"`
 }
 
 func (p *PacketClientMove) MustMarshalToBytes() []byte {
@@ -164,8 +182,11 @@ type PacketServerMoveResp struct {
 	PacketHeader
 	MoveRespType PacketTypeServerMoveRespType `json:"resp_type"`
 	// 下面的字段只有在状态OK的时候出现
-	TableOnOK  *chess.ChessTable `json:"table,omitempty"`
-	KingThreat bool              `json:"king_threat"`
+	TableOnOK        *chess.ChessTable `json:"table,omitempty"`
+	TableOnOKCompact string            `json:"table_compact,omitempty"`
+	KingThreat       bool              `json:"king_threat"`
+	// Crazyhouse中双方手里的棋子, 客户端以它为准, 旧的服务端不会发送
+	Pockets *chess.Pockets `json:"pockets,omitempty"`
 }
 
 func (p *PacketServerMoveResp) MustMarshalToBytes() []byte {
@@ -181,10 +202,15 @@ func (p *PacketServerMoveResp) MustMarshalToBytes() []byte {
 
 type PacketServerGameOver struct {
 	PacketHeader
-	Table       *chess.ChessTable `json:"final_table"`
-	WinnerSide  chess.Side        `json:"winner_side"`
-	IsSurrender bool              `json:"is_surrender"`
-	IsDraw      bool              `json:"is_draw"`
+	Table        *chess.ChessTable `json:"final_table"`
+	TableCompact string            `json:"final_table_compact,omitempty"`
+	WinnerSide   chess.Side        `json:"winner_side"`
+	IsSurrender  bool              `json:"is_surrender"`
+	IsDraw       bool              `json:"is_draw"`
+	// 结束的原因, 变体特有的结束条件只能通过这里得知, 旧的服务端不会发送
+	Reason chess.GameResultReason `json:"reason,omitempty"`
+	// Crazyhouse中双方手里的棋子, 客户端以它为准, 旧的服务端不会发送
+	Pockets *chess.Pockets `json:"pockets,omitempty"`
 }
 
 func (p *PacketServerGameOver) MustMarshalToBytes() []byte {
@@ -216,9 +242,12 @@ func (p *PacketServerRemoteLoseConnection) MustMarshalToBytes() []byte {
 type PacketServerNotifyRemoteMove struct {
 	PacketHeader
 	Table             *chess.ChessTable `json:"table"`
+	TableCompact      string            `json:"table_compact,omitempty"`
 	RemotePawnUpgrade bool              `json:"remote_pawn_upgrade"`
 	KingThreat        bool              `json:"king_threat"`
 	RemoteRequestDraw bool              `json:"RemoteRequestDraw"`
+	// Crazyhouse中双方手里的棋子, 客户端以它为准, 旧的服务端不会发送
+	Pockets *chess.Pockets `json:"pockets,omitempty"`
 }
 
 func (p *PacketServerNotifyRemoteMove) MustMarshalToBytes() []byte {
@@ -266,7 +295,10 @@ func (p *PacketClientDoSurrender) MustMarshalToBytes() []byte {
 type PacketServerRemoteUpgradeOK struct {
 	PacketHeader
 	Table             *chess.ChessTable `json:"table"`
+	TableCompact      string            `json:"table_compact,omitempty"`
 	RemoteRequestDraw bool              `json:"remote_request_draw"`
+	// Crazyhouse中双方手里的棋子, 客户端以它为准, 旧的服务端不会发送
+	Pockets *chess.Pockets `json:"pockets,omitempty"`
 }
 
 func (p *PacketServerRemoteUpgradeOK) MustMarshalToBytes() []byte {
@@ -282,7 +314,10 @@ func (p *PacketServerRemoteUpgradeOK) MustMarshalToBytes() []byte {
 
 type PacketServerUpgradeOK struct {
 	PacketHeader
-	Table *chess.ChessTable `json:"table"`
+	Table        *chess.ChessTable `json:"table"`
+	TableCompact string            `json:"table_compact,omitempty"`
+	// Crazyhouse中双方手里的棋子, 客户端以它为准, 旧的服务端不会发送
+	Pockets *chess.Pockets `json:"pockets,omitempty"`
 }
 
 func (p *PacketServerUpgradeOK) MustMarshalToBytes() []byte {
@@ -0,0 +1,56 @@
+package packets
+
+import (
+	"chess-frontend/comm/chess"
+	"encoding/json"
+	"testing"
+)
+
+// 易位时带上castling字段, 普通移动不带, 旧的服务端看到的包和以前一样
+func TestPacketClientMoveCastling(t *testing.T) {
+	for _, c := range []struct {
+		castling bool
+		want     bool
+	}{
+		{false, false},
+		{true, true},
+	} {
+		p := PacketClientMove{FromX: 'b', FromY: 1, ToX: 'c', ToY: 1, Castling: c.castling}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(p.MustMarshalToBytes(), &fields); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := fields["castling"]; ok != c.want {
+			t.Errorf("castling %v: field present %v, want %v", c.castling, ok, c.want)
+		}
+	}
+}
+
+// 手里的棋子不属于棋盘编码, 两种编码下都原样传过来
+func TestServerPacketPockets(t *testing.T) {
+	pockets := chess.Pockets{}
+	pockets[chess.SideWhite][chess.ChessPieceTypeKnight] = 2
+	pockets[chess.SideBlack][chess.ChessPieceTypePawn] = 1
+
+	for _, compact := range []bool{false, true} {
+		p := PacketServerNotifyRemoteMove{Table: chess.NewChessTable(), Pockets: &pockets}
+		if compact {
+			p.Compact()
+		}
+		got, ok := ClientParse(p.MustMarshalToBytes()).(*PacketServerNotifyRemoteMove)
+		if !ok {
+			t.Fatalf("compact %v: ClientParse did not return a remote move packet", compact)
+		}
+		if got.Pockets == nil || *got.Pockets != pockets {
+			t.Errorf("compact %v: pockets %v, want %v", compact, got.Pockets, pockets)
+		}
+	}
+
+	// 没有手里的棋子时不发送这个字段
+	p := PacketServerNotifyRemoteMove{Table: chess.NewChessTable()}
+	var fields map[string]interface{}
+	json.Unmarshal(p.MustMarshalToBytes(), &fields)
+	if _, ok := fields["pockets"]; ok {
+		t.Error("pockets sent without a value")
+	}
+}
@@ -1,5 +1,7 @@
 package settings
 
+import "chess-frontend/comm/chess"
+
 // 下面是心跳配置, 最多耗时1s就能检测到对方是否断线
 // 客户端和服务端丢需要检测, 双方都进行判定
 
@@ -12,3 +14,9 @@ const MaxLoseHeartbeat = 5
 // 服务端的配置
 const ServerListenIP = "127.0.0.1"
 const ServerListenPort = 8080
+
+// 客户端是否请求紧凑的棋盘编码, 服务端不支持时仍然使用完整的JSON
+const CompactTable = true
+
+// 匹配时请求的变体, 比如chess.VariantChess960
+const Variant = chess.VariantStandard
@@ -2,11 +2,12 @@ module chess-frontend
 
 go 1.20
 
+require github.com/markity/Interactive-Console v0.0.0-20230607024959-dde303d32075
+
 require (
 	github.com/gdamore/encoding v1.0.0 // indirect
 	github.com/gdamore/tcell v1.4.0 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
-	github.com/markity/Interactive-Console v0.0.0-20230607024959-dde303d32075 // indirect
 	github.com/mattn/go-runewidth v0.0.14 // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
 	golang.org/x/sys v0.7.0 // indirect
@@ -7,6 +7,7 @@ import (
 	"chess-frontend/tools"
 	"fmt"
 	"net"
+	"os"
 	"time"
 
 	interactive "github.com/markity/Interactive-Console"
@@ -22,14 +23,14 @@ const (
 
 func main() {
 	// 连上服务端
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", settings.ServerListenIP, settings.ServerListenPort))
+	conn, err := net.Dial("tcp", net.JoinHostPort(settings.ServerListenIP, fmt.Sprint(settings.ServerListenPort)))
 	if err != nil {
 		fmt.Printf("failed to dial to server: %v\n", err)
 		return
 	}
 
 	// 连接完成后发送start_match指令
-	startMatchPacket := packets.PacketClientStartMatch{}
+	startMatchPacket := packets.PacketClientStartMatch{CompactTable: settings.CompactTable, Variant: settings.Variant}
 	startMatchPacketBytesWithHeader := tools.DoPackWith4BytesHeader(startMatchPacket.MustMarshalToBytes())
 	_, err = conn.Write(startMatchPacketBytesWithHeader)
 	if err != nil {
@@ -56,6 +57,9 @@ func main() {
 
 	var win *interactive.Win
 
+	// 对局记录, 游戏结束时导出成PGN
+	var record *tools.GameRecord
+
 	errChan := make(chan error, 1)
 	readFromConnChan := make(chan interface{})
 	heartbeatChan := time.NewTicker(settings.HeartbeatInterval * time.Millisecond)
@@ -90,6 +94,11 @@ func main() {
 				conn.Close()
 				win.Stop()
 				fmt.Println("你认输了")
+				if path, err := savePGN(record, selfSide.Opponent(), true, false, chess.GameResultReasonResignation); err != nil {
+					fmt.Printf("保存棋谱失败: %v\n", err)
+				} else {
+					fmt.Println("棋谱已保存到" + path)
+				}
 				return
 			case tools.CommandTypeEmpty:
 				// do nothing
@@ -165,7 +174,7 @@ func main() {
 					waitingAcceptDraw = false
 					myTrun = true
 				}
-			case tools.CommandTypeMove, tools.CommandTypeMoveAndDraw:
+			case tools.CommandTypeMove, tools.CommandTypeMoveAndDraw, tools.CommandTypeDrop:
 				if waitingGameover {
 					continue
 				}
@@ -191,18 +200,44 @@ func main() {
 					continue
 				}
 
-				if pattern.MoveFromX == pattern.MoveToX && pattern.MoveFromY == pattern.MoveToY {
-					win.SendLineBackWithColor(style, "两个坐标不能一样")
+				if pattern.Type == tools.CommandTypeDrop {
+					if record.Game.Variant() != chess.VariantCrazyhouse {
+						win.SendLineBackWithColor(style, "只有Crazyhouse可以打入")
+						win.SetBlockInput(false)
+						continue
+					}
+					if record.Game.Position.Pockets[selfSide][pattern.Drop] == 0 {
+						win.SendLineBackWithColor(style, "你手里没有这个棋子")
+						win.SetBlockInput(false)
+						continue
+					}
+				} else if pattern.Castling {
+					m, ok := record.Game.Position.CastlingMove(pattern.Kingside)
+					if !ok {
+						win.SendLineBackWithColor(style, "现在不能这样易位")
+						win.SetBlockInput(false)
+						continue
+					}
+					pattern.MoveFromX, pattern.MoveFromY, pattern.MoveToX, pattern.MoveToY = m.FromX, m.FromY, m.ToX, m.ToY
+				} else if pattern.MoveFromX == pattern.MoveToX && pattern.MoveFromY == pattern.MoveToY {
+					win.SendLineBackWithColor(style, "两个坐标不能一样, 易位请用mov O-O或者mov O-O-O")
 					win.SetBlockInput(false)
 					continue
+				} else if m, ok := record.Game.Position.MoveBySquares(pattern.MoveFromX, pattern.MoveFromY, pattern.MoveToX, pattern.MoveToY); ok && m.Type == chess.MoveTypeCastling {
+					// 标准规则中mov e1 g1这样的写法只可能是易位
+					pattern.Castling = true
 				}
 
 				movePacket := packets.PacketClientMove{
-					FromX:  pattern.MoveFromX,
-					FromY:  pattern.MoveFromY,
-					ToX:    pattern.MoveToX,
-					ToY:    pattern.MoveToY,
-					DoDraw: pattern.Type == tools.CommandTypeMoveAndDraw,
+					FromX:    pattern.MoveFromX,
+					FromY:    pattern.MoveFromY,
+					ToX:      pattern.MoveToX,
+					ToY:      pattern.MoveToY,
+					DoDraw:   pattern.Type == tools.CommandTypeMoveAndDraw,
+					Castling: pattern.Castling,
+				}
+				if pattern.Type == tools.CommandTypeDrop {
+					movePacket.Drop = &pattern.Drop
 				}
 				movePacketBytesWithHeader := tools.DoPackWith4BytesHeader(movePacket.MustMarshalToBytes())
 				_, err := conn.Write(movePacketBytesWithHeader)
@@ -263,7 +298,22 @@ func main() {
 				if packet.IsDraw {
 					msg += ", 发起和棋"
 				}
-				tools.Draw(win, packet.Table, &msg)
+				// 最后一个棋盘上轮到谁取决于谁走了最后一步, 这里假设它刚好是下一步
+				msg = updateRecord(record, packet.Table, packet.Pockets, record.Game.SideToMove().Opponent(), msg)
+				switch packet.Reason {
+				case chess.GameResultReasonKingOfTheHill:
+					msg += ", 王到达了中心"
+				case chess.GameResultReasonThreeCheck:
+					msg += ", 第三次将军"
+				case chess.GameResultReasonExplosion:
+					msg += ", 王被炸掉了"
+				}
+				if path, err := savePGN(record, packet.WinnerSide, packet.IsSurrender, packet.IsDraw, packet.Reason); err != nil {
+					msg += fmt.Sprintf(", 保存棋谱失败: %v", err)
+				} else {
+					msg += ", 棋谱已保存到" + path
+				}
+				drawTable(win, record, packet.Table, &msg)
 				time.Sleep(time.Second * 3)
 				win.Stop()
 				return
@@ -278,6 +328,7 @@ func main() {
 
 				gameState = GameStateGaming
 				selfSide = packet.Side
+				record = tools.NewGameRecord(packet.Table, packet.Pockets, packet.Variant)
 				if selfSide == chess.SideWhite {
 					myTrun = true
 				} else {
@@ -288,15 +339,14 @@ func main() {
 				winSettings.BlockInputAfterEnter = true
 				win = interactive.Run(winSettings)
 				cmdChan = win.GetCmdChan()
-				var msg *string
+				var msg string
 				if myTrun {
-					m := "你是白方, 你先手"
-					msg = &m
+					msg = "你是白方, 你先手"
 				} else {
-					m := "你是黑方, 对方先手"
-					msg = &m
+					msg = "你是黑方, 对方先手"
 				}
-				tools.Draw(win, packet.Table, msg)
+				msg = checkTable(record, packet.Table, chess.SideWhite, msg)
+				drawTable(win, record, packet.Table, &msg)
 			case *packets.PacketServerMatching:
 				if gameState != GameStateNone {
 					if win != nil {
@@ -334,7 +384,9 @@ func main() {
 						msg += ", 正在将军"
 					}
 					waitingMoveResp = false
-					tools.Draw(win, packet.TableOnOK, &msg)
+					msg = updateRecord(record, packet.TableOnOK, packet.Pockets, selfSide.Opponent(), msg)
+					msg = checkTable(record, packet.TableOnOK, selfSide.Opponent(), msg)
+					drawTable(win, record, packet.TableOnOK, &msg)
 					win.SetBlockInput(false)
 					continue
 				}
@@ -343,7 +395,7 @@ func main() {
 					waitingUpgrade = true
 					win.SetBlockInput(false)
 					msg := "你可以升级, swi bishop/queen/rook/knight"
-					tools.Draw(win, packet.TableOnOK, &msg)
+					drawTable(win, record, packet.TableOnOK, &msg)
 					continue
 				}
 			case *packets.PacketServerNotifyRemoteMove:
@@ -358,8 +410,9 @@ func main() {
 				if packet.RemoteRequestDraw {
 					waitingAcceptDraw = true
 					myTrun = false
-					msg := "对方请求议和, accept接受, refuse拒绝"
-					tools.Draw(win, packet.Table, &msg)
+					msg := updateRecord(record, packet.Table, packet.Pockets, selfSide, "对方请求议和, accept接受, refuse拒绝")
+					msg = checkTable(record, packet.Table, selfSide, msg)
+					drawTable(win, record, packet.Table, &msg)
 					continue
 				}
 
@@ -367,7 +420,7 @@ func main() {
 					msg := "请等待对方升级"
 					myTrun = false
 					waitingRemoteUpgradeOK = true
-					tools.Draw(win, packet.Table, &msg)
+					drawTable(win, record, packet.Table, &msg)
 					continue
 				}
 
@@ -376,7 +429,9 @@ func main() {
 					msg += ", 将军!"
 				}
 				myTrun = true
-				tools.Draw(win, packet.Table, &msg)
+				msg = updateRecord(record, packet.Table, packet.Pockets, selfSide, msg)
+				msg = checkTable(record, packet.Table, selfSide, msg)
+				drawTable(win, record, packet.Table, &msg)
 			case *packets.PacketServerRemoteLoseConnection:
 				if gameState != GameStateGaming {
 					win.Stop()
@@ -396,13 +451,16 @@ func main() {
 				waitingRemoteUpgradeOK = false
 				if packet.RemoteRequestDraw {
 					waitingAcceptDraw = true
-					msg := "对方请求议和, accept接受, refuse拒绝"
-					tools.Draw(win, packet.Table, &msg)
+					msg := updateRecord(record, packet.Table, packet.Pockets, selfSide, "对方请求议和, accept接受, refuse拒绝")
+					msg = checkTable(record, packet.Table, selfSide, msg)
+					drawTable(win, record, packet.Table, &msg)
 					continue
 				}
 				msg := "现在是你的回合"
 				myTrun = true
-				tools.Draw(win, packet.Table, &msg)
+				msg = updateRecord(record, packet.Table, packet.Pockets, selfSide, msg)
+				msg = checkTable(record, packet.Table, selfSide, msg)
+				drawTable(win, record, packet.Table, &msg)
 			case *packets.PacketServerUpgradeOK:
 				if !waitingUpgradeOKResp {
 					win.Stop()
@@ -412,8 +470,9 @@ func main() {
 
 				waitingUpgradeOKResp = false
 				myTrun = false
-				msg := "现在是对方的回合"
-				tools.Draw(win, packet.Table, &msg)
+				msg := updateRecord(record, packet.Table, packet.Pockets, selfSide.Opponent(), "现在是对方的回合")
+				msg = checkTable(record, packet.Table, selfSide.Opponent(), msg)
+				drawTable(win, record, packet.Table, &msg)
 				win.SetBlockInput(false)
 			default:
 				win.Stop()
@@ -423,3 +482,73 @@ func main() {
 		}
 	}
 }
+
+// 画出棋盘, Crazyhouse时在棋盘旁边画出双方手里的棋子
+func drawTable(win *interactive.Win, record *tools.GameRecord, table *chess.ChessTable, message *string) {
+	if record.Game.Variant() != chess.VariantCrazyhouse {
+		tools.Draw(win, table, message)
+		return
+	}
+	tools.DrawWithPockets(win, table, &record.Game.Position.Pockets, message)
+}
+
+// 用服务端发来的棋盘和手里的棋子更新对局记录, 对不上时在提示信息后面说明
+// 之后画出的手里的棋子和打入前的检查都以记录为准, 所以它们和服务端保持一致
+func updateRecord(record *tools.GameRecord, table *chess.ChessTable, pockets *chess.Pockets, sideToMove chess.Side, msg string) string {
+	if !record.Update(table, pockets, sideToMove) {
+		return msg + ", 本地记录和服务端不同步, 已按服务端的棋盘重新记录"
+	}
+	return msg
+}
+
+// 检查服务端发来的棋盘, 有问题时在提示信息后面附上第一个错误
+// 按照对局的变体检查, 比如Atomic中王可能已经被炸掉
+func checkTable(record *tools.GameRecord, table *chess.ChessTable, sideToMove chess.Side, msg string) string {
+	if errs := table.ValidateVariant(sideToMove, record.Game.Variant()); len(errs) > 0 {
+		return msg + fmt.Sprintf(", 棋盘数据异常: %v", errs[0])
+	}
+	return msg
+}
+
+// 把对局保存成PGN文件, 返回文件路径, reason是服务端告知的结束原因, 没有时为GameResultReasonNone
+func savePGN(record *tools.GameRecord, winner chess.Side, isSurrender bool, isDraw bool, reason chess.GameResultReason) (string, error) {
+	now := time.Now()
+
+	// 将死和逼和已经在记录着法时判定过了, 认输和同意和棋只能从服务端得知
+	if reason == chess.GameResultReasonNone {
+		reason = record.Game.Reason
+	}
+	if isSurrender {
+		reason = chess.GameResultReasonResignation
+	}
+	if isDraw {
+		reason = chess.GameResultReasonAgreement
+	}
+	record.Game.SetResult(chess.GameResultFromWinner(winner), reason)
+
+	pgn := record.Game.PGN()
+	pgn.SetTag("Event", "Online game")
+	pgn.SetTag("Site", net.JoinHostPort(settings.ServerListenIP, fmt.Sprint(settings.ServerListenPort)))
+	pgn.SetTag("Date", now.Format("2006.01.02"))
+
+	if isSurrender {
+		if winner == chess.SideWhite {
+			pgn.FinalComment = "黑方认输"
+		} else {
+			pgn.FinalComment = "白方认输"
+		}
+	}
+	if isDraw {
+		pgn.FinalComment = "双方同意和棋"
+	}
+	if record.Incomplete {
+		note := "棋谱不完整, 和服务端不同步之后从FEN标签的局面重新记录"
+		if pgn.FinalComment != "" {
+			note = pgn.FinalComment + ", " + note
+		}
+		pgn.FinalComment = note
+	}
+
+	path := fmt.Sprintf("chess-%s.pgn", now.Format("20060102-150405"))
+	return path, os.WriteFile(path, []byte(pgn.String()), 0644)
+}
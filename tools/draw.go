@@ -7,33 +7,37 @@ import (
 	interactive "github.com/markity/Interactive-Console"
 )
 
-func mustGetName(piece *chess.ChessPiece) string {
-	if piece == nil {
-		return "  "
+func Draw(win *interactive.Win, table *chess.ChessTable, message *string) {
+	DrawWithPockets(win, table, nil, message)
+}
+
+// Crazyhouse手里的棋子, 比如"黑方手中: 后1 马2"
+func pocketLine(pockets *chess.Pockets, side chess.Side) string {
+	s := "白方手中:"
+	if side == chess.SideBlack {
+		s = "黑方手中:"
 	}
-	switch piece.PieceType {
-	case chess.ChessPieceTypeRook:
-		return "车"
-	case chess.ChessPieceTypeBishop:
-		return "象"
-	case chess.ChessPieceTypeKnight:
-		return "马"
-	case chess.ChessPieceTypeQueen:
-		return "后"
-	case chess.ChessPieceTypeKing:
-		return "王"
-	case chess.ChessPieceTypePawn:
-		return "兵"
-	default:
-		panic("unreachable")
+	types := [5]chess.ChessPieceType{chess.ChessPieceTypeQueen, chess.ChessPieceTypeRook, chess.ChessPieceTypeBishop, chess.ChessPieceTypeKnight, chess.ChessPieceTypePawn}
+	for _, t := range types {
+		if n := pockets[side][t]; n > 0 {
+			s += " " + PieceGlyph(&chess.ChessPiece{PieceType: t, GameSide: side}, TextGlyphsChinese) + fmt.Sprint(n)
+		}
 	}
+	return s
 }
 
-func Draw(win *interactive.Win, table *chess.ChessTable, message *string) {
+// 和Draw相同, pockets不为nil时在棋盘上方和下方分别画出黑方和白方手里的棋子
+func DrawWithPockets(win *interactive.Win, table *chess.ChessTable, pockets *chess.Pockets, message *string) {
 	win.Clear()
 	style1 := interactive.GetDefaultSytleAttr()
 	style1.Foreground = interactive.ColorForestGreen
 
+	stylePocket := interactive.GetDefaultSytleAttr()
+	if pockets != nil {
+		stylePocket.Foreground = interactive.ColorDarkGrey
+		win.SendLineBackWithColor(stylePocket, pocketLine(pockets, chess.SideBlack))
+	}
+
 	win.SendLineBackWithColor(style1, "   a b c d e f g h    ")
 
 	for i := 7; i >= 0; i-- {
@@ -49,7 +53,7 @@ func Draw(win *interactive.Win, table *chess.ChessTable, message *string) {
 				}
 			}
 
-			tobeSend = append(tobeSend, style2, mustGetName(table.GetIndex(j, i)), style2)
+			tobeSend = append(tobeSend, style2, PieceGlyph(table.GetIndex(j, i), TextGlyphsChinese), style2)
 		}
 		tobeSend = append(tobeSend, style1, " "+fmt.Sprint(i+1)+" ")
 		win.SendLineBackWithColor(tobeSend...)
@@ -57,6 +61,11 @@ func Draw(win *interactive.Win, table *chess.ChessTable, message *string) {
 
 	win.SendLineBackWithColor(style1, "   a b c d e f g h    ")
 
+	if pockets != nil {
+		stylePocket.Foreground = interactive.ColorGhostWhite
+		win.SendLineBackWithColor(stylePocket, pocketLine(pockets, chess.SideWhite))
+	}
+
 	style2 := interactive.GetDefaultSytleAttr()
 	style2.Foreground = interactive.ColorLightPink
 	if message != nil {
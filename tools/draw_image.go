@@ -0,0 +1,276 @@
+package tools
+
+import (
+	"chess-frontend/comm/chess"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+)
+
+const imageDefaultSquareSize = 48
+
+// 调色板中颜色的下标, PNG和GIF共用同一个调色板
+const (
+	imageColorLight uint8 = iota
+	imageColorDark
+	imageColorLastMoveLight
+	imageColorLastMoveDark
+	imageColorCheck
+	imageColorWhitePiece
+	imageColorWhiteOutline
+	imageColorBlackPiece
+	imageColorBlackOutline
+)
+
+var imagePalette = color.Palette{
+	imageColorLight:         color.RGBA{0xf0, 0xd9, 0xb5, 0xff},
+	imageColorDark:          color.RGBA{0xb5, 0x88, 0x63, 0xff},
+	imageColorLastMoveLight: color.RGBA{0xce, 0xd2, 0x6b, 0xff},
+	imageColorLastMoveDark:  color.RGBA{0xaa, 0xa2, 0x3a, 0xff},
+	imageColorCheck:         color.RGBA{0xe0, 0x40, 0x40, 0xff},
+	imageColorWhitePiece:    color.RGBA{0xff, 0xff, 0xff, 0xff},
+	imageColorWhiteOutline:  color.RGBA{0x00, 0x00, 0x00, 0xff},
+	imageColorBlackPiece:    color.RGBA{0x10, 0x10, 0x10, 0xff},
+	imageColorBlackOutline:  color.RGBA{0x90, 0x90, 0x90, 0xff},
+}
+
+// 棋子的点阵, #是棋子本身, 紧挨着#的空白会画成轮廓
+const imageGlyphSize = 16
+
+var imageGlyphs = map[chess.ChessPieceType][imageGlyphSize]string{
+	chess.ChessPieceTypePawn: {
+		"................",
+		"................",
+		"................",
+		"......####......",
+		".....######.....",
+		".....######.....",
+		"......####......",
+		".....######.....",
+		"......####......",
+		"......####......",
+		".....######.....",
+		"....########....",
+		"...##########...",
+		"...##########...",
+		"................",
+		"................",
+	},
+	chess.ChessPieceTypeRook: {
+		"................",
+		"................",
+		"...##..##..##...",
+		"...##..##..##...",
+		"...##########...",
+		"....########....",
+		".....######.....",
+		".....######.....",
+		".....##..##.....",
+		".....######.....",
+		".....######.....",
+		"....########....",
+		"...##########...",
+		"...##########...",
+		"................",
+		"................",
+	},
+	chess.ChessPieceTypeKnight: {
+		"................",
+		"................",
+		"......##.#......",
+		".....#######....",
+		"....#########...",
+		"...####.#####...",
+		"...##########...",
+		"..###########...",
+		"..####..#####...",
+		".......######...",
+		"......######....",
+		".....#######....",
+		"....#########...",
+		"...##########...",
+		"................",
+		"................",
+	},
+	chess.ChessPieceTypeBishop: {
+		"................",
+		".......##.......",
+		"......####......",
+		".....###.##.....",
+		".....##.###.....",
+		".....######.....",
+		"......####......",
+		".......##.......",
+		".....######.....",
+		"......####......",
+		"......####......",
+		".....######.....",
+		"...##########...",
+		"...##########...",
+		"................",
+		"................",
+	},
+	chess.ChessPieceTypeQueen: {
+		"................",
+		".#.....##.....#.",
+		".##...####...##.",
+		".##..#.##.#..##.",
+		".###.######.###.",
+		"..############..",
+		"..############..",
+		"...##########...",
+		"....########....",
+		".....######.....",
+		"....########....",
+		"...##########...",
+		"..############..",
+		"..############..",
+		"................",
+		"................",
+	},
+	chess.ChessPieceTypeKing: {
+		"................",
+		".......##.......",
+		"......####......",
+		".......##.......",
+		"..####.##.####..",
+		".##############.",
+		".######..######.",
+		".##############.",
+		"..############..",
+		"...##########...",
+		"....########....",
+		".....######.....",
+		"....########....",
+		"...##########...",
+		"...##########...",
+		"................",
+	},
+}
+
+// 点阵中的一个点是否属于棋子, 越界时返回false
+func imageGlyphFilled(glyph *[imageGlyphSize]string, gx int, gy int) bool {
+	if gx < 0 || gx >= imageGlyphSize || gy < 0 || gy >= imageGlyphSize {
+		return false
+	}
+	return glyph[gy][gx] == '#'
+}
+
+type ImageOptions struct {
+	// 每个格子的边长, 单位是像素, 为0时使用默认值
+	SquareSize int
+	// 从黑方的视角画, a1在右上角
+	Flipped bool
+	// 高亮上一步的起点和终点, nil表示不高亮
+	LastMove *chess.Move
+	// 高亮正在被将军的王所在的格子
+	HighlightCheck bool
+}
+
+// 把棋盘画成图片, 不依赖字体, 棋子用内置的点阵缩放
+func RenderImage(table *chess.ChessTable, opts ImageOptions) *image.Paletted {
+	size := opts.SquareSize
+	if size <= 0 {
+		size = imageDefaultSquareSize
+	}
+	img := image.NewPaletted(image.Rect(0, 0, size*8, size*8), imagePalette)
+
+	highlighted := [64]bool{}
+	if opts.LastMove != nil {
+		if x, y, ok := chess.PositionToIndex(opts.LastMove.FromX, opts.LastMove.FromY); ok {
+			highlighted[y*8+x] = true
+		}
+		if x, y, ok := chess.PositionToIndex(opts.LastMove.ToX, opts.LastMove.ToY); ok {
+			highlighted[y*8+x] = true
+		}
+	}
+	inCheck := [2]bool{}
+	if opts.HighlightCheck {
+		inCheck[chess.SideWhite] = table.InCheck(chess.SideWhite)
+		inCheck[chess.SideBlack] = table.InCheck(chess.SideBlack)
+	}
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			piece := table.GetIndex(x, y)
+
+			// 格子左上角在图片中的坐标
+			left, top := x*size, (7-y)*size
+			if opts.Flipped {
+				left, top = (7-x)*size, y*size
+			}
+
+			bg := imageColorLight
+			if highlighted[y*8+x] {
+				bg = imageColorLastMoveLight
+			}
+			if (x+y)%2 == 0 {
+				bg = imageColorDark
+				if highlighted[y*8+x] {
+					bg = imageColorLastMoveDark
+				}
+			}
+			if piece != nil && piece.PieceType == chess.ChessPieceTypeKing &&
+				(piece.GameSide == chess.SideWhite || piece.GameSide == chess.SideBlack) && inCheck[piece.GameSide] {
+				bg = imageColorCheck
+			}
+
+			var glyph *[imageGlyphSize]string
+			fill, outline := imageColorWhitePiece, imageColorWhiteOutline
+			if piece != nil {
+				if g, ok := imageGlyphs[piece.PieceType]; ok {
+					glyph = &g
+				}
+				if piece.GameSide == chess.SideBlack {
+					fill, outline = imageColorBlackPiece, imageColorBlackOutline
+				}
+			}
+
+			for py := 0; py < size; py++ {
+				for px := 0; px < size; px++ {
+					c := bg
+					if glyph != nil {
+						gx, gy := px*imageGlyphSize/size, py*imageGlyphSize/size
+						if imageGlyphFilled(glyph, gx, gy) {
+							c = fill
+						} else if imageGlyphFilled(glyph, gx-1, gy) || imageGlyphFilled(glyph, gx+1, gy) ||
+							imageGlyphFilled(glyph, gx, gy-1) || imageGlyphFilled(glyph, gx, gy+1) {
+							c = outline
+						}
+					}
+					img.SetColorIndex(left+px, top+py, c)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+func EncodePNG(w io.Writer, table *chess.ChessTable, opts ImageOptions) error {
+	return png.Encode(w, RenderImage(table, opts))
+}
+
+// 把一盘对局画成GIF动画, 第一帧是开始局面, 之后每一步一帧
+// delay是每一帧的停留时间, 单位是1/100秒, 最后一帧停留三倍的时间
+// opts.LastMove会被忽略, 每一帧都会高亮刚走的那一步
+// 在局面上执行着法, 所以Crazyhouse的打入也能画出来
+func EncodeGameGIF(w io.Writer, start *chess.Position, moves []chess.Move, opts ImageOptions, delay int) error {
+	p := start.Copy()
+	opts.LastMove = nil
+
+	anim := &gif.GIF{}
+	anim.Image = append(anim.Image, RenderImage(p.Table, opts))
+	anim.Delay = append(anim.Delay, delay)
+	for i := range moves {
+		p.MakeMove(moves[i])
+		opts.LastMove = &moves[i]
+		anim.Image = append(anim.Image, RenderImage(p.Table, opts))
+		anim.Delay = append(anim.Delay, delay)
+	}
+	anim.Delay[len(anim.Delay)-1] = delay * 3
+
+	return gif.EncodeAll(w, anim)
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"chess-frontend/comm/chess"
+	"fmt"
+	"strings"
+)
+
+const (
+	svgDefaultSquareSize = 45
+	svgLightSquare       = "#f0d9b5"
+	svgDarkSquare        = "#b58863"
+	svgLastMoveColor     = "#cdd26a"
+	svgCheckColor        = "#ff0000"
+	svgArrowColor        = "#15781b"
+	svgCoordinateColor   = "#555555"
+)
+
+// 箭头, 从一个格子的中心指向另一个格子的中心
+type SVGArrow struct {
+	FromX rune
+	FromY int
+	ToX   rune
+	ToY   int
+}
+
+type SVGOptions struct {
+	// 每个格子的边长, 为0时使用默认值
+	SquareSize int
+	// 从黑方的视角画, a1在右上角
+	Flipped bool
+	// 在棋盘四周画坐标
+	Coordinates bool
+	// 高亮上一步的起点和终点, nil表示不高亮
+	LastMove *chess.Move
+	// 高亮正在被将军的王
+	HighlightCheck bool
+	Arrows         []SVGArrow
+}
+
+// 实心的国际象棋字符, 白方和黑方使用相同的字形, 靠填充颜色区分
+func svgPieceGlyph(t chess.ChessPieceType) string {
+	switch t {
+	case chess.ChessPieceTypeRook:
+		return "♜"
+	case chess.ChessPieceTypeKnight:
+		return "♞"
+	case chess.ChessPieceTypeBishop:
+		return "♝"
+	case chess.ChessPieceTypeQueen:
+		return "♛"
+	case chess.ChessPieceTypeKing:
+		return "♚"
+	case chess.ChessPieceTypePawn:
+		return "♟"
+	default:
+		panic("unreachable")
+	}
+}
+
+// 把一个棋盘画成独立的SVG文件
+func RenderSVG(table *chess.ChessTable, opts SVGOptions) string {
+	size := opts.SquareSize
+	if size <= 0 {
+		size = svgDefaultSquareSize
+	}
+	margin := 0
+	if opts.Coordinates {
+		margin = size / 2
+	}
+	total := size*8 + margin*2
+
+	// 格子左上角在图片中的坐标
+	origin := func(x int, y int) (int, int) {
+		if opts.Flipped {
+			return margin + (7-x)*size, margin + y*size
+		}
+		return margin + x*size, margin + (7-y)*size
+	}
+	center := func(X rune, Y int) (int, int) {
+		x, y := chess.MustPositionToIndex(X, Y)
+		px, py := origin(x, y)
+		return px + size/2, py + size/2
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		total, total, total, total)
+	fmt.Fprintf(&sb, `<defs><marker id="arrowhead" viewBox="0 0 10 10" refX="5" refY="5" markerWidth="3" markerHeight="3" orient="auto">`+
+		`<path d="M0,0 L10,5 L0,10 z" fill="%s"/></marker></defs>`+"\n", svgArrowColor)
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			px, py := origin(x, y)
+			color := svgLightSquare
+			if (x+y)%2 == 0 {
+				color = svgDarkSquare
+			}
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", px, py, size, size, color)
+		}
+	}
+
+	if opts.LastMove != nil {
+		for _, sq := range [2][2]int{{int(opts.LastMove.FromX), opts.LastMove.FromY}, {int(opts.LastMove.ToX), opts.LastMove.ToY}} {
+			// 打入没有起点
+			x, y, ok := chess.PositionToIndex(rune(sq[0]), sq[1])
+			if !ok {
+				continue
+			}
+			px, py := origin(x, y)
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" fill-opacity="0.6"/>`+"\n",
+				px, py, size, size, svgLastMoveColor)
+		}
+	}
+
+	if opts.HighlightCheck {
+		for _, side := range [2]chess.Side{chess.SideWhite, chess.SideBlack} {
+			if !table.InCheck(side) {
+				continue
+			}
+			for i := 0; i < 64; i++ {
+				p := table[i]
+				if p != nil && p.PieceType == chess.ChessPieceTypeKing && p.GameSide == side {
+					cx, cy := center(p.X, p.Y)
+					fmt.Fprintf(&sb, `<circle cx="%d" cy="%d" r="%d" fill="%s" fill-opacity="0.5"/>`+"\n",
+						cx, cy, size/2, svgCheckColor)
+				}
+			}
+		}
+	}
+
+	if opts.Coordinates {
+		fontSize := size / 3
+		for i := 0; i < 8; i++ {
+			file, rank := rune('a'+i), i+1
+			fx, _ := origin(i, 0)
+			_, ry := origin(0, i)
+			fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" fill="%s" text-anchor="middle">%c</text>`+"\n",
+				fx+size/2, margin-fontSize/2, fontSize, svgCoordinateColor, file)
+			fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" fill="%s" text-anchor="middle">%c</text>`+"\n",
+				fx+size/2, total-margin/2+fontSize/3, fontSize, svgCoordinateColor, file)
+			fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" fill="%s" text-anchor="middle">%d</text>`+"\n",
+				margin/2, ry+size/2+fontSize/3, fontSize, svgCoordinateColor, rank)
+			fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" fill="%s" text-anchor="middle">%d</text>`+"\n",
+				total-margin/2, ry+size/2+fontSize/3, fontSize, svgCoordinateColor, rank)
+		}
+	}
+
+	for i := 0; i < 64; i++ {
+		p := table[i]
+		if p == nil {
+			continue
+		}
+		px, py := origin(i%8, i/8)
+		fill, stroke := "#ffffff", "#000000"
+		if p.GameSide == chess.SideBlack {
+			fill, stroke = "#000000", "#ffffff"
+		}
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" text-anchor="middle" fill="%s" stroke="%s" stroke-width="1">%s</text>`+"\n",
+			px+size/2, py+size*4/5, size*4/5, fill, stroke, svgPieceGlyph(p.PieceType))
+	}
+
+	for _, a := range opts.Arrows {
+		x1, y1 := center(a.FromX, a.FromY)
+		x2, y2 := center(a.ToX, a.ToY)
+		fmt.Fprintf(&sb, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="%d" stroke-opacity="0.8" stroke-linecap="round" marker-end="url(#arrowhead)"/>`+"\n",
+			x1, y1, x2, y2, svgArrowColor, size/6)
+	}
+
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
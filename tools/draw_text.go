@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"chess-frontend/comm/chess"
+	"fmt"
+	"strings"
+)
+
+type TextGlyphs int
+
+const (
+	// 车马象后王兵, 不区分双方, 只能靠颜色分辨
+	TextGlyphsChinese TextGlyphs = iota
+	// FEN中的字母, 白方大写, 黑方小写
+	TextGlyphsASCII
+	// Unicode中的国际象棋符号
+	TextGlyphsUnicode
+)
+
+const (
+	ansiReset       = "\x1b[0m"
+	ansiLightSquare = "\x1b[48;5;180m"
+	ansiDarkSquare  = "\x1b[48;5;137m"
+	ansiWhitePiece  = "\x1b[97m"
+	ansiBlackPiece  = "\x1b[30m"
+	ansiCoordinate  = "\x1b[32m"
+)
+
+type TextOptions struct {
+	Glyphs TextGlyphs
+	// 从黑方的视角画, a1在右上角
+	Flipped bool
+	// 使用ANSI转义序列给格子和棋子上色
+	Color bool
+}
+
+// 一个格子的文字, 在终端中总是占两列, 空格子也一样
+func PieceGlyph(piece *chess.ChessPiece, glyphs TextGlyphs) string {
+	if piece == nil {
+		switch glyphs {
+		case TextGlyphsASCII:
+			return ". "
+		case TextGlyphsUnicode:
+			return "· "
+		default:
+			return "  "
+		}
+	}
+
+	var names [6]string
+	switch glyphs {
+	case TextGlyphsASCII:
+		names = [6]string{"R ", "N ", "B ", "Q ", "K ", "P "}
+		if piece.GameSide == chess.SideBlack {
+			names = [6]string{"r ", "n ", "b ", "q ", "k ", "p "}
+		}
+	case TextGlyphsUnicode:
+		names = [6]string{"♖ ", "♘ ", "♗ ", "♕ ", "♔ ", "♙ "}
+		if piece.GameSide == chess.SideBlack {
+			names = [6]string{"♜ ", "♞ ", "♝ ", "♛ ", "♚ ", "♟ "}
+		}
+	default:
+		names = [6]string{"车", "马", "象", "后", "王", "兵"}
+	}
+
+	if piece.PieceType < chess.ChessPieceTypeRook || piece.PieceType > chess.ChessPieceTypePawn {
+		panic("unreachable")
+	}
+	return names[piece.PieceType]
+}
+
+// 把棋盘画成多行文字, 四周带坐标, 不依赖任何终端
+func RenderText(table *chess.ChessTable, opts TextOptions) string {
+	files := "   a b c d e f g h    "
+	if opts.Flipped {
+		files = "   h g f e d c b a    "
+	}
+	coordinate := func(s string) string {
+		if opts.Color {
+			return ansiCoordinate + s + ansiReset
+		}
+		return s
+	}
+
+	var sb strings.Builder
+	sb.WriteString(coordinate(files) + "\n")
+	for row := 0; row < 8; row++ {
+		y := 7 - row
+		if opts.Flipped {
+			y = row
+		}
+		rank := " " + fmt.Sprint(y+1) + " "
+
+		sb.WriteString(coordinate(rank))
+		for col := 0; col < 8; col++ {
+			x := col
+			if opts.Flipped {
+				x = 7 - col
+			}
+			piece := table.GetIndex(x, y)
+			glyph := PieceGlyph(piece, opts.Glyphs)
+			if opts.Color {
+				bg := ansiLightSquare
+				if (x+y)%2 == 0 {
+					bg = ansiDarkSquare
+				}
+				fg := ""
+				if piece != nil {
+					fg = ansiWhitePiece
+					if piece.GameSide == chess.SideBlack {
+						fg = ansiBlackPiece
+					}
+				}
+				glyph = bg + fg + glyph + ansiReset
+			}
+			sb.WriteString(glyph)
+		}
+		sb.WriteString(coordinate(rank) + "\n")
+	}
+	sb.WriteString(coordinate(files) + "\n")
+
+	return sb.String()
+}
@@ -21,6 +21,8 @@ const (
 	// 是否同一平局
 	CommandTypeAccept
 	CommandTypeRefuse
+	// drop, Crazyhouse中打入手里的棋子
+	CommandTypeDrop
 )
 
 type CommandPattern struct {
@@ -33,6 +35,13 @@ type CommandPattern struct {
 	MoveToY int
 
 	Swi chess.ChessPieceType
+
+	// 打入的棋子, 打到MoveToX, MoveToY
+	Drop chess.ChessPieceType
+
+	// mov O-O和mov O-O-O, 这时没有坐标
+	Castling bool
+	Kingside bool
 }
 
 func runeAlphaToIndex(i rune) (int, bool) {
@@ -103,6 +112,22 @@ func ParseCommand(s string) *CommandPattern {
 		return &CommandPattern{Type: CommandTypeUnkonwn}
 	}
 
+	// 易位用O-O和O-O-O表示, Chess960中只看坐标无法和王的普通移动区分
+	if len(fields) == 2 && (fields[0] == "mov" || fields[0] == "dmov") {
+		p := CommandTypeMove
+		if fields[0] == "dmov" {
+			p = CommandTypeMoveAndDraw
+		}
+		switch strings.ToUpper(fields[1]) {
+		case "O-O", "0-0":
+			return &CommandPattern{Type: p, Castling: true, Kingside: true}
+		case "O-O-O", "0-0-0":
+			return &CommandPattern{Type: p, Castling: true}
+		default:
+			return &CommandPattern{Type: CommandTypeUnkonwn}
+		}
+	}
+
 	if len(fields) == 2 {
 		if fields[0] != "swi" {
 			return &CommandPattern{Type: CommandTypeSwitch}
@@ -122,6 +147,38 @@ func ParseCommand(s string) *CommandPattern {
 		}
 	}
 
+	if len(fields) == 3 && fields[0] == "drop" {
+		to := []rune(fields[2])
+		if len(to) != 2 {
+			return &CommandPattern{Type: CommandTypeUnkonwn}
+		}
+		_, ok := runeAlphaToIndex(to[0])
+		if !ok {
+			return &CommandPattern{Type: CommandTypeUnkonwn}
+		}
+		toy, ok := runeNumToIndex(to[1])
+		if !ok {
+			return &CommandPattern{Type: CommandTypeUnkonwn}
+		}
+
+		var t chess.ChessPieceType
+		switch strings.ToUpper(fields[1]) {
+		case "P":
+			t = chess.ChessPieceTypePawn
+		case "N":
+			t = chess.ChessPieceTypeKnight
+		case "B":
+			t = chess.ChessPieceTypeBishop
+		case "R":
+			t = chess.ChessPieceTypeRook
+		case "Q":
+			t = chess.ChessPieceTypeQueen
+		default:
+			return &CommandPattern{Type: CommandTypeUnkonwn}
+		}
+		return &CommandPattern{Type: CommandTypeDrop, Drop: t, MoveToX: to[0], MoveToY: toy}
+	}
+
 	if len(fields) == 3 {
 		if fields[0] != "mov" && fields[0] != "dmov" {
 			return &CommandPattern{Type: CommandTypeUnkonwn}
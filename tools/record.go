@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"chess-frontend/comm/chess"
+	"os"
+)
+
+// 导出GIF时每一帧停留的时间, 单位是1/100秒
+const recordGIFDelay = 100
+
+// 对局记录, 服务端只发送棋盘, 这里通过对比前后两个棋盘还原出每一步
+type GameRecord struct {
+	Game *chess.Game
+	// 和服务端不同步过, Game是从收到的棋盘重新开始记录的, 之前的着法已经丢失
+	Incomplete bool
+}
+
+// pockets是服务端发来的双方手里的棋子, 没有时为nil
+func NewGameRecord(start *chess.ChessTable, pockets *chess.Pockets, variant chess.Variant) *GameRecord {
+	p := chess.NewPositionFromTable(start.Copy(), chess.SideWhite)
+	if pockets != nil {
+		p.Pockets = *pockets
+		p.Hash = p.ComputeHash()
+	}
+	return &GameRecord{
+		Game: chess.NewVariantGame(p, variant),
+	}
+}
+
+// 收到新的棋盘时调用, sideToMove是这个棋盘上轮到的一方, pockets是服务端发来的双方手里的棋子, 没有时为nil
+// 和上一个棋盘相同, 或者是兵升变时等待选择的中间棋盘时忽略
+// 找不到对应的着法, 或者手里的棋子和服务端的不一样, 说明不同步了, 这时从收到的局面重新开始记录, 返回false
+func (r *GameRecord) Update(table *chess.ChessTable, pockets *chess.Pockets, sideToMove chess.Side) bool {
+	if table == nil || waitingPromotion(table) {
+		return true
+	}
+	if !r.Game.Position.Table.SamePlacement(table) {
+		m, ok := r.Game.Position.FindMove(table)
+		if !ok {
+			r.resync(table, pockets, sideToMove)
+			return false
+		}
+		r.Game.MakeMove(m)
+	}
+	if pockets != nil && *pockets != r.Game.Position.Pockets {
+		r.resync(table, pockets, sideToMove)
+		return false
+	}
+	return true
+}
+
+// 兵走到了底线但是还没有升变, 服务端在等待选择升变的棋子
+func waitingPromotion(table *chess.ChessTable) bool {
+	for _, p := range table {
+		if p != nil && p.PieceType == chess.ChessPieceTypePawn && (p.Y == 1 || p.Y == 8) {
+			return true
+		}
+	}
+	return false
+}
+
+// 以收到的局面作为开始局面重新记录, 回合数接着之前的算, 服务端没有发来手里的棋子时沿用之前的
+// 重复局面的历史也从这里重新开始
+func (r *GameRecord) resync(table *chess.ChessTable, pockets *chess.Pockets, sideToMove chess.Side) {
+	old := r.Game.Position
+	p := chess.NewPositionFromTable(table.Copy(), sideToMove)
+	p.FullmoveNumber = old.FullmoveNumber
+	if old.SideToMove == chess.SideBlack && sideToMove == chess.SideWhite {
+		p.FullmoveNumber++
+	}
+	p.Pockets = old.Pockets
+	if pockets != nil {
+		p.Pockets = *pockets
+	}
+	p.Hash = p.ComputeHash()
+
+	g := chess.NewVariantGame(p, r.Game.Variant())
+	g.StartTime = r.Game.StartTime
+	r.Game = g
+	r.Incomplete = true
+}
+
+// 把对局保存成GIF动画, 每一步一帧
+func (r *GameRecord) SaveGIF(path string) error {
+	moves := make([]chess.Move, 0, len(r.Game.Moves))
+	for _, m := range r.Game.Moves {
+		moves = append(moves, m.Move)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := EncodeGameGIF(f, r.Game.Start, moves, ImageOptions{HighlightCheck: true}, recordGIFDelay); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
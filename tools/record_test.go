@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"chess-frontend/comm/chess"
+	"testing"
+)
+
+func tableAfter(t *testing.T, p *chess.Position, sans ...string) *chess.Position {
+	t.Helper()
+	p = p.Copy()
+	for _, san := range sans {
+		m, err := p.ParseSAN(san)
+		if err != nil {
+			t.Fatalf("%s: %v", san, err)
+		}
+		p.MakeMove(m)
+	}
+	return p
+}
+
+func TestGameRecordUpdate(t *testing.T) {
+	start := chess.NewPosition()
+	start.Variant = chess.VariantCrazyhouse
+	r := NewGameRecord(start.Table, &chess.Pockets{}, chess.VariantCrazyhouse)
+
+	// 正常的一步, 以及重复收到同一个棋盘
+	p := tableAfter(t, start, "e4")
+	if !r.Update(p.Table, &p.Pockets, chess.SideBlack) || !r.Update(p.Table, &p.Pockets, chess.SideBlack) {
+		t.Fatal("update with the next table failed")
+	}
+	if len(r.Game.Moves) != 1 || r.Incomplete {
+		t.Fatalf("got %d moves, incomplete %v", len(r.Game.Moves), r.Incomplete)
+	}
+
+	// 漏掉了一个棋盘, 从收到的局面重新记录
+	p = tableAfter(t, p, "d5", "exd5")
+	if r.Update(p.Table, &p.Pockets, chess.SideBlack) {
+		t.Fatal("update after a missed table succeeded")
+	}
+	if !r.Incomplete || r.Game.SideToMove() != chess.SideBlack || !r.Game.Position.Table.SamePlacement(p.Table) {
+		t.Fatalf("record not rebuilt from the received table")
+	}
+	if r.Game.Position.Pockets != p.Pockets {
+		t.Errorf("pockets %v, want %v", r.Game.Position.Pockets, p.Pockets)
+	}
+
+	// 棋盘对得上但是手里的棋子不一样时以服务端为准
+	p = tableAfter(t, p, "Qxd5")
+	server := p.Pockets
+	server[chess.SideWhite][chess.ChessPieceTypeQueen] = 1
+	if r.Update(p.Table, &server, chess.SideWhite) {
+		t.Fatal("update with different pockets succeeded")
+	}
+	if r.Game.Position.Pockets != server {
+		t.Errorf("pockets %v, want %v", r.Game.Position.Pockets, server)
+	}
+}
+
+// 兵走到底线等待升变的棋盘不是一步完整的棋, 直接忽略
+func TestGameRecordSkipsPromotionBoard(t *testing.T) {
+	start, err := chess.ParsePositionFEN("4k3/P7/8/8/8/8/8/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewGameRecord(start.Table, nil, chess.VariantStandard)
+	r.Game = chess.NewGame(start)
+
+	waiting := start.Table.Copy()
+	pawn := waiting.ClearPosition('a', 7)
+	pawn.Y = 8
+	waiting.SetPosition(pawn)
+	if !r.Update(waiting, nil, chess.SideWhite) || r.Incomplete || len(r.Game.Moves) != 0 {
+		t.Fatal("promotion board was not skipped")
+	}
+
+	promoted := tableAfter(t, start, "a8=Q+")
+	if !r.Update(promoted.Table, nil, chess.SideBlack) || len(r.Game.Moves) != 1 {
+		t.Fatal("promotion was not recorded")
+	}
+}